@@ -0,0 +1,338 @@
+package grest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+//boundKey to save the struct filled by Bind[T]() in the context
+const boundKey contextKey = "bound"
+
+//FieldError describes why a single field could not be bound or validated
+type FieldError struct {
+	//Source of the field: path, query, header, json or body (empty for Data and structs validated directly)
+	Source string `json:"source,omitempty"`
+	//Field is the name of the field in its source
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+//Error formats the FieldError like: query limit: not an integer
+func (e FieldError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("%s: %s", e.Field, e.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Source, e.Field, e.Message)
+}
+
+//FieldErrors is a list of errors for single fields, it is an error itself
+type FieldErrors []FieldError
+
+//Error joins the messages of all field errors
+func (errs FieldErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+//Bind fills the struct T from the request and puts it into the WebUnit where following WebParts can read it with BoundAs[T](u)
+//The fields of T are filled according to their tags:
+//	`path:"id"` from the named parameters of ParamPath
+//	`query:"limit"` from the URL query
+//	`header:"X-Tenant"` from the request header
+//	`json:"name"` from the request body (parsed like Body() if that did not happen before)
+//Time fields are parsed with the layout given in the tag `format:"2006-01-02"` (default time.RFC3339, "unix" for unix timestamps).
+//If any field cannot be converted the WebUnit panics with status 400 and FieldErrors listing all failed fields
+func Bind[T any]() WebPart {
+	return func(u WebUnit) *WebUnit {
+		var v T
+		if err := u.Bind(&v); err != nil {
+			u.Panic(err)
+			return &u
+		}
+		u.Context = context.WithValue(u.Context, boundKey, v)
+		return &u
+	}
+}
+
+//BoundAs returns the struct filled by Bind[T]()
+//The second return value is false if nothing was bound into a T
+func BoundAs[T any](u WebUnit) (T, bool) {
+	v, ok := u.Context.Value(boundKey).(T)
+	return v, ok
+}
+
+//Bind fills the struct target points to from the request (see Bind[T]() for the supported tags)
+//Returns a StatusError with 400 wrapping FieldErrors if any field cannot be converted
+func (u *WebUnit) Bind(target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Bind needs a pointer to a struct but got %T", target)
+	}
+
+	sources := map[string]Data{
+		"path":   u.PathParams(),
+		"query":  valuesToData(u.Request.URL.Query()),
+		"header": valuesToData(u.Request.Header),
+	}
+	if hasTag(rv.Elem().Type(), "json") {
+		body, err := bindBody(u)
+		if err != nil {
+			return err
+		}
+		sources["json"] = body
+	}
+
+	var errs FieldErrors
+	bindStruct(rv.Elem(), sources, &errs)
+	if len(errs) > 0 {
+		return StatusError{http.StatusBadRequest, errs}
+	}
+	return nil
+}
+
+//=== Helpers =====================================================================================
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	decimalType = reflect.TypeOf(decimal.Decimal{})
+)
+
+//hasTag returns true if any (embedded) field of t has the tag
+func hasTag(t reflect.Type, tag string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if _, ok := f.Tag.Lookup(tag); ok {
+			return true
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && hasTag(f.Type, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+//bindBody returns the parsed request body, parsing it with Body() if necessary (an empty body results in empty Data)
+func bindBody(u *WebUnit) (Data, error) {
+	if d := u.BodyData(); d != nil {
+		return d, nil
+	}
+	data, err := readBody(u)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return Data{}, nil
+	}
+	result := Body()(*u)
+	if err := result.GetPanic(); err != nil {
+		return nil, err
+	}
+	*u = *result
+	return u.BodyData(), nil
+}
+
+//bindStruct fills all tagged fields of the struct v
+func bindStruct(v reflect.Value, sources map[string]Data, errs *FieldErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			bindStruct(v.Field(i), sources, errs)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		for _, source := range []string{"path", "query", "header", "json"} {
+			tag, ok := f.Tag.Lookup(source)
+			if !ok {
+				continue
+			}
+			key := strings.Split(tag, ",")[0]
+			if key == "-" {
+				continue
+			}
+			if key == "" {
+				key = f.Name
+			}
+			d := sources[source]
+			if source == "header" {
+				key = textproto.CanonicalMIMEHeaderKey(key)
+			} else if source == "json" {
+				key = jsonKey(d, key)
+			}
+			if !d.Has(key) {
+				continue
+			}
+			if err := setField(v.Field(i), d, key, f.Tag.Get("format")); err != nil {
+				*errs = append(*errs, FieldError{Source: source, Field: key, Message: err.Error()})
+			}
+		}
+	}
+}
+
+//jsonKey returns the key of d matching name, preferring an exact match like encoding/json
+func jsonKey(d Data, name string) string {
+	if d.Has(name) {
+		return name
+	}
+	for k := range d {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
+
+//textValue returns v if it is a string or a single query/header value, ok is false for all other types
+func textValue(v interface{}) (s string, ok bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case []string:
+		if len(t) == 1 {
+			return t[0], true
+		}
+	}
+	return "", false
+}
+
+//setField converts the value of d[key] with the Data accessors and sets it to field
+func setField(field reflect.Value, d Data, key, format string) error {
+	if field.Kind() == reflect.Ptr {
+		if d[key] == nil {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		value := reflect.New(field.Type().Elem())
+		if err := setField(value.Elem(), d, key, format); err != nil {
+			return err
+		}
+		field.Set(value)
+		return nil
+	}
+
+	if d[key] == nil {
+		//null leaves the field unset like encoding/json does
+		return nil
+	}
+
+	switch field.Type() {
+	case timeType:
+		var t *time.Time
+		if format == "unix" {
+			t = d.UnixTime(key)
+		} else {
+			if format == "" {
+				format = time.RFC3339
+			}
+			t = d.Time(key, format)
+		}
+		if t == nil {
+			return fmt.Errorf("not a time in the format %s", format)
+		}
+		field.Set(reflect.ValueOf(*t))
+		return nil
+	case decimalType:
+		dd := d.Decimal(key)
+		if dd == nil {
+			return fmt.Errorf("not a decimal")
+		}
+		field.Set(reflect.ValueOf(*dd))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		s, ok := textValue(d[key])
+		if !ok {
+			return fmt.Errorf("not a string")
+		}
+		field.SetString(s)
+	case reflect.Bool:
+		if b, ok := d[key].(bool); ok {
+			field.SetBool(b)
+			return nil
+		}
+		s, ok := textValue(d[key])
+		b, err := strconv.ParseBool(s)
+		if !ok || err != nil {
+			return fmt.Errorf("not a boolean")
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := d.Int64(key)
+		if i == nil {
+			return fmt.Errorf("not an integer")
+		}
+		if field.OverflowInt(*i) {
+			return fmt.Errorf("integer out of range")
+		}
+		field.SetInt(*i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := d.Uint64(key)
+		if u == nil {
+			return fmt.Errorf("not an unsigned integer")
+		}
+		if field.OverflowUint(*u) {
+			return fmt.Errorf("integer out of range")
+		}
+		field.SetUint(*u)
+	case reflect.Float32, reflect.Float64:
+		dd := d.Decimal(key)
+		if dd == nil {
+			return fmt.Errorf("not a number")
+		}
+		f, _ := dd.Float64()
+		if field.OverflowFloat(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("number out of range")
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			s, ok := textValue(d[key])
+			if !ok {
+				return fmt.Errorf("not a string")
+			}
+			field.SetBytes([]byte(s))
+			return nil
+		}
+		values := reflect.ValueOf(d[key])
+		if values.Kind() != reflect.Slice {
+			values = reflect.ValueOf([]interface{}{d[key]})
+		}
+		slice := reflect.MakeSlice(field.Type(), values.Len(), values.Len())
+		for i := 0; i < values.Len(); i++ {
+			if err := setField(slice.Index(i), Data{key: values.Index(i).Interface()}, key, format); err != nil {
+				return fmt.Errorf("element %d: %v", i, err)
+			}
+		}
+		field.Set(slice)
+	default:
+		//structs, maps, ... are converted via JSON
+		data, err := json.Marshal(d[key])
+		if err != nil {
+			return err
+		}
+		value := reflect.New(field.Type())
+		if err := json.Unmarshal(data, value.Interface()); err != nil {
+			return fmt.Errorf("expected %s", field.Type())
+		}
+		field.Set(value.Elem())
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+package grest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+//bodyKey to save the parsed request body in the context
+const bodyKey contextKey = "body"
+
+//bodyLimitKey to save the limit set by MaxBodySize in the context
+const bodyLimitKey contextKey = "bodyLimit"
+
+//DefaultBodyLimit is the maximum number of bytes the body parsing WebParts read from a request body if no MaxBodySize was set
+const DefaultBodyLimit int64 = 10 << 20
+
+//MaxBodySize limits the request body to n bytes.
+//Reading more than n bytes from Request.Body returns a StatusError with 413 (Request Entity Too Large), which the body parsing WebParts put into the panic context.
+//The body parsing WebParts use n instead of DefaultBodyLimit
+func MaxBodySize(n int64) WebPart {
+	return func(u WebUnit) *WebUnit {
+		req := *u.Request
+		if req.Body != nil {
+			req.Body = MakeClosable(&limitReader{req.Body, n, tooLarge(n)}, req.Body.Close)
+		}
+		u.Request = &req
+		u.Context = context.WithValue(u.Context, bodyLimitKey, n)
+		return &u
+	}
+}
+
+//MaxBodySize limits the request body to n bytes.
+//Reading more than n bytes from Request.Body returns a StatusError with 413 (Request Entity Too Large), which the body parsing WebParts put into the panic context.
+//The body parsing WebParts use n instead of DefaultBodyLimit
+func (w WebPart) MaxBodySize(n int64) WebPart {
+	return Compose(w, MaxBodySize(n))
+}
+
+//Decompress transparently decompresses request bodies with "Content-Encoding: gzip" or "deflate" for the following WebParts (use it before any body parsing WebPart).
+//The decompressed body is limited to the body limit (see MaxBodySize, DefaultBodyLimit) to protect against zip bombs, a MaxBodySize before Decompress limits the compressed size.
+//Bodies with another Content-Encoding result in a panic with status 415 (Unsupported Media Type), corrupted bodies in a 400 when they are read
+func Decompress() WebPart {
+	return func(u WebUnit) *WebUnit {
+		return DecompressWith(bodyLimit(u))(u)
+	}
+}
+
+//Decompress transparently decompresses request bodies with "Content-Encoding: gzip" or "deflate" for the following WebParts (use it before any body parsing WebPart).
+//The decompressed body is limited to the body limit (see MaxBodySize, DefaultBodyLimit) to protect against zip bombs, a MaxBodySize before Decompress limits the compressed size.
+//Bodies with another Content-Encoding result in a panic with status 415 (Unsupported Media Type), corrupted bodies in a 400 when they are read
+func (w WebPart) Decompress() WebPart {
+	return Compose(w, Decompress())
+}
+
+//DecompressWith works like Decompress() but limits the decompressed body to maxSize bytes (413 Request Entity Too Large)
+func DecompressWith(maxSize int64) WebPart {
+	return func(u WebUnit) *WebUnit {
+		var encodings []string
+		for _, e := range strings.Split(u.Request.Header.Get(HeaderKeyContentEncoding), ",") {
+			e = strings.ToLower(strings.TrimSpace(e))
+			switch e {
+			case "", "identity":
+			case "gzip", "x-gzip", "deflate":
+				encodings = append(encodings, e)
+			default:
+				u.Writer.Header().Set(HeaderKeyAcceptEncoding, "gzip, deflate")
+				u.Panic(NewStatusError(http.StatusUnsupportedMediaType, "unsupported Content-Encoding: %s", e))
+				return &u
+			}
+		}
+		if len(encodings) == 0 || u.Request.Body == nil {
+			return &u
+		}
+		req := *u.Request
+		req.Header = req.Header.Clone()
+		req.Header.Del(HeaderKeyContentEncoding)
+		req.Header.Del(HeaderKeyContentLength)
+		req.ContentLength = -1
+		d := &decompressReader{body: req.Body, encodings: encodings}
+		req.Body = MakeClosable(&limitReader{d, maxSize, NewStatusError(http.StatusRequestEntityTooLarge, "decompressed request body is larger than %d bytes", maxSize)}, d.Close)
+		u.Request = &req
+		return &u
+	}
+}
+
+//DecompressWith works like Decompress() but limits the decompressed body to maxSize bytes (413 Request Entity Too Large)
+func (w WebPart) DecompressWith(maxSize int64) WebPart {
+	return Compose(w, DecompressWith(maxSize))
+}
+
+//Body parses the request body into a Data object that can be read by the following WebParts with BodyData()
+//The body is expected to be a JSON object ("Content-Type: application/json" or no Content-Type at all), XML (see Data.UnmarshalXML) or a form (see Form()).
+//Bodies that cannot be parsed result in a panic with status 400 (415 for an unsupported Content-Type, 413 if the body is larger than the body limit, see MaxBodySize)
+func Body() WebPart {
+	return func(u WebUnit) *WebUnit {
+		var d Data
+		var err error
+		if isForm(mediaType(u)) {
+			d, err = parseForm(&u, FormOptions{})
+		} else {
+			err = decodeBody(&u, &d, jsonDecoder, xmlDecoder)
+		}
+		if err == nil && d == nil {
+			err = NewStatusError(http.StatusBadRequest, "request body is not an object")
+		}
+		if err != nil {
+			u.Panic(err)
+			return &u
+		}
+		u.Context = context.WithValue(u.Context, bodyKey, d)
+		return &u
+	}
+}
+
+//Body parses the request body into a Data object that can be read by the following WebParts with BodyData()
+//The body is expected to be a JSON object ("Content-Type: application/json" or no Content-Type at all), XML (see Data.UnmarshalXML) or a form (see Form()).
+//Bodies that cannot be parsed result in a panic with status 400 (415 for an unsupported Content-Type, 413 if the body is larger than the body limit, see MaxBodySize)
+func (w WebPart) Body() WebPart {
+	return Compose(w, Body())
+}
+
+//JSONBody decodes the JSON request body into a new T that can be read by the following WebParts with BodyAs[T](u)
+//Bodies that cannot be decoded into T result in a panic with status 400 (415 for an unsupported Content-Type, 413 if the body is larger than the body limit, see MaxBodySize)
+func JSONBody[T any]() WebPart {
+	return func(u WebUnit) *WebUnit {
+		var v T
+		if err := decodeBody(&u, &v, jsonDecoder); err != nil {
+			u.Panic(err)
+			return &u
+		}
+		u.Context = context.WithValue(u.Context, bodyKey, v)
+		return &u
+	}
+}
+
+//GetBody returns the request body parsed by Body(), JSONBody[T]() and the like or nil if the body was not parsed yet
+func (u WebUnit) GetBody() interface{} {
+	return u.Context.Value(bodyKey)
+}
+
+//BodyData returns the request body parsed by Body() or nil if the body was not parsed into a Data object
+func (u WebUnit) BodyData() Data {
+	d, _ := u.GetBody().(Data)
+	return d
+}
+
+//BodyAs returns the request body parsed by JSONBody[T]()
+//The second return value is false if the body was not parsed into a T
+func BodyAs[T any](u WebUnit) (T, bool) {
+	v, ok := u.GetBody().(T)
+	return v, ok
+}
+
+//=== Helpers =====================================================================================
+
+//bodyLimit returns the limit set by MaxBodySize or DefaultBodyLimit
+func bodyLimit(u WebUnit) int64 {
+	if limit, ok := u.Context.Value(bodyLimitKey).(int64); ok {
+		return limit
+	}
+	return DefaultBodyLimit
+}
+
+//readBody reads the whole request body (at most bodyLimit bytes)
+//The request body is replaced with the read bytes so it can be read again by following WebParts
+func readBody(u *WebUnit) ([]byte, error) {
+	if u.Request.Body == nil {
+		return nil, nil
+	}
+	limit := bodyLimit(*u)
+	data, err := ioutil.ReadAll(&limitReader{u.Request.Body, limit, tooLarge(limit)})
+	u.Request.Body.Close()
+	u.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	return data, nil
+}
+
+//tooLarge is the error for bodies larger than limit
+func tooLarge(limit int64) error {
+	return NewStatusError(http.StatusRequestEntityTooLarge, "request body is larger than %d bytes", limit)
+}
+
+//mediaType returns the media type of the requests Content-Type without parameters (lower case)
+func mediaType(u WebUnit) string {
+	contentType := u.Request.Header.Get(HeaderKeyContentType)
+	if contentType == "" {
+		return ""
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	return mt
+}
+
+//isJSON returns true for application/json and all +json types
+func isJSON(mediaType string) bool {
+	return mediaType == ContentTypeJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+//bodyDecoder decodes request bodies of the media types it accepts
+type bodyDecoder struct {
+	accepts func(mediaType string) bool
+	decode  func(data []byte, v interface{}) error
+}
+
+var (
+	jsonDecoder = bodyDecoder{isJSON, decodeJSON}
+	xmlDecoder  = bodyDecoder{isXML, decodeXML}
+)
+
+//decodeBody decodes the request body into v with the first decoder accepting the Content-Type (without Content-Type the first decoder is used)
+func decodeBody(u *WebUnit, v interface{}, decoders ...bodyDecoder) error {
+	mt := mediaType(*u)
+	decoder := decoders[0]
+	if mt != "" {
+		found := false
+		for _, d := range decoders {
+			if d.accepts(mt) {
+				decoder, found = d, true
+				break
+			}
+		}
+		if !found {
+			return NewStatusError(http.StatusUnsupportedMediaType, "unsupported Content-Type: %s", mt)
+		}
+	}
+	data, err := readBody(u)
+	if err != nil {
+		return err
+	}
+	return decoder.decode(data, v)
+}
+
+//decodeJSON decodes exactly one JSON value into v, numbers in Data objects are kept as json.Number
+func decodeJSON(data []byte, v interface{}) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return NewStatusError(http.StatusBadRequest, "request body is empty")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return NewStatusError(http.StatusBadRequest, "invalid value for %s: expected %s but got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+		return StatusError{http.StatusBadRequest, fmt.Errorf("malformed JSON body: %v", err)}
+	}
+	if dec.More() {
+		return NewStatusError(http.StatusBadRequest, "malformed JSON body: unexpected data after the JSON value")
+	}
+	return nil
+}
+
+//bodyError wraps errors that occur while reading a body into a 400 StatusError (if it is not already a StatusError)
+func bodyError(err error) error {
+	var se StatusError
+	if errors.As(err, &se) {
+		return err
+	}
+	return StatusError{http.StatusBadRequest, err}
+}
+
+//limitReader returns err as soon as more than n bytes are read from r
+type limitReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, l.err
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.n {
+		read := int(l.n)
+		l.n = -1
+		return read, l.err
+	}
+	l.n -= int64(n)
+	return n, err
+}
+
+//decompressReader decodes body with the given content encodings (in the order they were applied), the decoders are created on the first Read()
+type decompressReader struct {
+	body      io.ReadCloser
+	encodings []string
+	r         io.Reader
+	decoders  []io.Closer
+	err       error
+}
+
+func (d *decompressReader) Read(p []byte) (int, error) {
+	if d.r == nil && d.err == nil {
+		d.r = d.body
+		for i := len(d.encodings) - 1; i >= 0 && d.err == nil; i-- {
+			var rc io.ReadCloser
+			if rc, d.err = decoder(d.r, d.encodings[i]); d.err == nil {
+				d.r = rc
+				d.decoders = append(d.decoders, rc)
+			}
+		}
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	n, err := d.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = decompressError(err)
+	}
+	return n, err
+}
+
+//Close closes the decoders (outermost first) and the original body
+func (d *decompressReader) Close() error {
+	for i := len(d.decoders) - 1; i >= 0; i-- {
+		d.decoders[i].Close()
+	}
+	d.decoders = nil
+	return d.body.Close()
+}
+
+//decoder returns a reader decoding r with the content encoding
+//deflate accepts zlib streams (as defined by HTTP) and raw deflate streams (as sent by some clients)
+func decoder(r io.Reader, encoding string) (io.ReadCloser, error) {
+	if encoding == "deflate" {
+		br := bufio.NewReader(r)
+		head, _ := br.Peek(2)
+		if len(head) == 2 && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, decompressError(err)
+			}
+			return zr, nil
+		}
+		return flate.NewReader(br), nil
+	}
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, decompressError(err)
+	}
+	return gr, nil
+}
+
+//decompressError wraps errors of corrupted compressed bodies into a 400 StatusError
+func decompressError(err error) error {
+	var se StatusError
+	if errors.As(err, &se) {
+		return err
+	}
+	return StatusError{http.StatusBadRequest, fmt.Errorf("malformed compressed request body: %v", err)}
+}
+
+//countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,299 @@
+package grest
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func getTestContextWithBody(useURL, contentType, body string) WebUnit {
+	u := getTestContext(useURL)
+	u.Request.Method = http.MethodPost
+	u.Request.Header = http.Header{}
+	if contentType != "" {
+		u.Request.Header.Set(HeaderKeyContentType, contentType)
+	}
+	u.Request.Body = ioutil.NopCloser(strings.NewReader(body))
+	return u
+}
+
+func TestBody(t *testing.T) {
+	cases := []struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		{ContentTypeJSON, `{"a": 1, "b": {"c": "d"}}`, 0},
+		{"application/json; charset=utf-8", `{"a": 1}`, 0},
+		{"application/vnd.api+json", `{"a": 1}`, 0},
+		{"", `{"a": 1}`, 0},
+		{ContentTypeJSON, `{"a": 1`, http.StatusBadRequest},
+		{ContentTypeJSON, `[1, 2]`, http.StatusBadRequest},
+		{ContentTypeJSON, `null`, http.StatusBadRequest},
+		{ContentTypeJSON, `{"a": 1} {"a": 2}`, http.StatusBadRequest},
+		{ContentTypeJSON, ``, http.StatusBadRequest},
+		{ContentTypeText, `{"a": 1}`, http.StatusUnsupportedMediaType},
+	}
+
+	for _, c := range cases {
+		result := Body()(getTestContextWithBody("http://text.de/", c.contentType, c.body))
+		if result == nil {
+			t.Fatalf("Body() should never result in nil")
+		}
+		if result.GetPanicStatus() != c.status {
+			t.Errorf("Body() with %s %s should panic with %d but got %d (%v)", c.contentType, c.body, c.status, result.GetPanicStatus(), result.GetPanic())
+		}
+		if c.status == 0 && *result.BodyData().Int64("a") != 1 {
+			t.Errorf("Body() with %s %s should contain a=1 but was %v", c.contentType, c.body, result.BodyData())
+		}
+	}
+}
+
+func TestJSONBody(t *testing.T) {
+	type payload struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+
+	result := JSONBody[payload]()(getTestContextWithBody("http://text.de/", ContentTypeJSON, `{"name": "x", "count": 3}`))
+	if p, ok := BodyAs[payload](*result); !ok || p.Name != "x" || p.Count != 3 {
+		t.Errorf("JSONBody[payload]() decoded %v", result.GetBody())
+	}
+
+	result = JSONBody[payload]()(getTestContextWithBody("http://text.de/", ContentTypeJSON, `{"name": "x", "count": "3"}`))
+	if result.GetPanicStatus() != http.StatusBadRequest {
+		t.Errorf("JSONBody[payload]() with wrong typed field should panic with 400 but got %v", result.GetPanic())
+	}
+	if _, ok := BodyAs[payload](*result); ok {
+		t.Errorf("JSONBody[payload]() should not store a body on error")
+	}
+}
+
+func TestForm(t *testing.T) {
+	result := Form()(getTestContextWithBody("http://text.de/", ContentTypeForm, "a=1&b=x&b=y"))
+	d := result.BodyData()
+	if result.GetPanic() != nil || *d.String("a") != "1" || len(d["b"].([]string)) != 2 {
+		t.Errorf("Form() parsed %v (%v)", d, result.GetPanic())
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("name", "test")
+	fw, _ := mw.CreateFormFile("upload", "hello.txt")
+	fw.Write([]byte(strings.Repeat("hello ", 100)))
+	mw.Close()
+
+	cases := []struct {
+		options FormOptions
+		status  int
+	}{
+		{FormOptions{}, 0},
+		{FormOptions{SpillSize: 10, TempDir: t.TempDir()}, 0},
+		{FormOptions{AllowedTypes: []string{"text/*"}}, 0},
+		{FormOptions{AllowedTypes: []string{"image/png"}}, http.StatusUnsupportedMediaType},
+		{FormOptions{MaxFileSize: 10}, http.StatusRequestEntityTooLarge},
+		{FormOptions{MaxTotalSize: 100}, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, c := range cases {
+		tu := getTestContextWithBody("http://text.de/", mw.FormDataContentType(), body.String())
+		u, done := NewWebUnit(tu.Writer, tu.Request)
+		result := FormWith(c.options)(u)
+		if result.GetPanicStatus() != c.status {
+			t.Errorf("FormWith(%+v) should panic with %d but got %v", c.options, c.status, result.GetPanic())
+			continue
+		}
+		if c.status != 0 {
+			continue
+		}
+		f := result.FormFile("upload")
+		if *result.BodyData().String("name") != "test" || f == nil || f.Size != 600 || f.Filename != "hello.txt" {
+			t.Errorf("FormWith(%+v) parsed %v %+v", c.options, result.BodyData(), f)
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadAll(r)
+		r.Close()
+		if string(content) != strings.Repeat("hello ", 100) {
+			t.Errorf("FormWith(%+v) stored wrong file content", c.options)
+		}
+		done()
+		if f.path != "" {
+			if _, err := os.Stat(f.path); !os.IsNotExist(err) {
+				t.Errorf("temporary file %s should be removed when the request is finished", f.path)
+			}
+		}
+	}
+
+	for _, options := range []FormOptions{{}, {SpillSize: 10, TempDir: t.TempDir()}} {
+		tu := getTestContextWithBody("http://text.de/", mw.FormDataContentType(), body.String()[:body.Len()/2])
+		u, done := NewWebUnit(tu.Writer, tu.Request)
+		if result := FormWith(options)(u); result.GetPanicStatus() != http.StatusBadRequest {
+			t.Errorf("FormWith(%+v) should panic with 400 for a truncated upload but got %v", options, result.GetPanic())
+		}
+		done()
+	}
+
+	dir := t.TempDir()
+	result = FormWith(FormOptions{SpillSize: 10, TempDir: dir})(getTestContextWithBody("http://text.de/", mw.FormDataContentType(), body.String()))
+	if files, _ := ioutil.ReadDir(dir); !errors.Is(result.GetPanic(), ErrNoDefer) || len(files) != 0 {
+		t.Errorf("spilling without deferred functions should fail without leaving files but got %v (%d files)", result.GetPanic(), len(files))
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	u := getTestContextWithBody("http://text.de/", ContentTypeJSON, `{"a": "0123456789"}`)
+	if result := MaxBodySize(10).Body()(u); result.GetPanicStatus() != http.StatusRequestEntityTooLarge {
+		t.Errorf("MaxBodySize(10).Body() should panic with 413 but got %v", result.GetPanic())
+	}
+
+	u = getTestContextWithBody("http://text.de/", ContentTypeJSON, `{"a": "0123456789"}`)
+	if result := MaxBodySize(100).Body()(u); result.GetPanic() != nil {
+		t.Errorf("MaxBodySize(100).Body() should not panic but got %v", result.GetPanic())
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	compress := func(encoding, s string) string {
+		var b bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&b)
+		case "deflate":
+			w = zlib.NewWriter(&b)
+		default:
+			w, _ = flate.NewWriter(&b, flate.DefaultCompression)
+		}
+		w.Write([]byte(s))
+		w.Close()
+		return b.String()
+	}
+	cases := []struct {
+		encoding string
+		body     string
+		part     WebPart
+		status   int
+	}{
+		{"gzip", compress("gzip", `{"a": 1}`), Decompress().Body(), 0},
+		{"deflate", compress("deflate", `{"a": 1}`), Decompress().Body(), 0},
+		{"deflate", compress("raw", `{"a": 1}`), Decompress().Body(), 0},
+		{"", `{"a": 1}`, Decompress().Body(), 0},
+		{"gzip", `{"a": 1}`, Decompress().Body(), http.StatusBadRequest},
+		{"br", `{"a": 1}`, Decompress().Body(), http.StatusUnsupportedMediaType},
+		{"gzip", compress("gzip", `{"a": "`+strings.Repeat("0", 1000)+`"}`), DecompressWith(100).Body(), http.StatusRequestEntityTooLarge},
+	}
+	for i, c := range cases {
+		u := getTestContextWithBody("http://text.de/", ContentTypeJSON, c.body)
+		u.Request.Header.Set(HeaderKeyContentEncoding, c.encoding)
+		result := c.part(u)
+		if result.GetPanicStatus() != c.status {
+			t.Errorf("case %d should have status %d but was %v", i, c.status, result.GetPanic())
+		} else if c.status == 0 && *result.BodyData().Int64("a") != 1 {
+			t.Errorf("case %d should be decompressed but was %v", i, result.BodyData())
+		}
+	}
+
+	u := getTestContextWithBody("http://text.de/", ContentTypeJSON, "")
+	body := &closeRecorder{Reader: strings.NewReader(compress("gzip", `{"a": 1}`))}
+	u.Request.Body = body
+	u.Request.Header.Set(HeaderKeyContentEncoding, "gzip")
+	result := Decompress()(u)
+	ioutil.ReadAll(result.Request.Body)
+	if result.Request.Body.Close(); !body.closed {
+		t.Errorf("closing the decompressed body should close the original body")
+	}
+}
+
+//closeRecorder remembers if Close was called
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestStreamRecords(t *testing.T) {
+	var sum int64
+	sut := StreamRecords(func(u WebUnit, record Data) error {
+		sum += *record.Int64("n")
+		return nil
+	})
+
+	result := sut(getTestContextWithBody("http://text.de/", "application/x-ndjson", "{\"n\": 1}\n\n{\"n\": 2}\r\n{\"n\": 3}"))
+	if result.GetPanic() != nil || sum != 6 {
+		t.Errorf("StreamRecords summed %d (%v)", sum, result.GetPanic())
+	}
+
+	result = sut(getTestContextWithBody("http://text.de/", "application/x-ndjson", "{\"n\": 1}\n{\"n\": "))
+	if result.GetPanicStatus() != http.StatusBadRequest || !strings.HasPrefix(result.GetPanic().Error(), "line 2:") {
+		t.Errorf("StreamRecords should panic with 400 in line 2 but got %v", result.GetPanic())
+	}
+}
+
+func TestBind(t *testing.T) {
+	type request struct {
+		ID     int64           `path:"id"`
+		Limit  *int            `query:"limit"`
+		Tags   []string        `query:"tag"`
+		Tenant string          `header:"x-tenant"`
+		Name   string          `json:"name"`
+		Price  decimal.Decimal `json:"price"`
+		Since  time.Time       `json:"since" format:"2006-01-02"`
+		Meta   struct {
+			A int `json:"a"`
+		} `json:"meta"`
+	}
+
+	u := getTestContextWithBody("http://text.de/items/42?limit=10&tag=a&tag=b", ContentTypeJSON, `{"name": "x", "price": "1.5", "since": "2020-01-31", "meta": {"a": 1}}`)
+	u.Request.Header.Set("X-Tenant", "t1")
+	result := Compose(ParamPath("/items/{id}"), Bind[request]())(u)
+	r, ok := BoundAs[request](*result)
+	if !ok || r.ID != 42 || *r.Limit != 10 || len(r.Tags) != 2 || r.Tenant != "t1" || r.Name != "x" || r.Price.String() != "1.5" || r.Since.Day() != 31 || r.Meta.A != 1 {
+		t.Errorf("Bind[request]() bound %+v (%v)", r, result.GetPanic())
+	}
+
+	u = getTestContextWithBody("http://text.de/items/x?limit=ten", ContentTypeJSON, `{"since": "yesterday"}`)
+	result = Compose(ParamPath("/items/{id}"), Bind[request]())(u)
+	var errs FieldErrors
+	if result.GetPanicStatus() != http.StatusBadRequest || !errors.As(result.GetPanic(), &errs) || len(errs) != 3 {
+		t.Errorf("Bind[request]() should panic with 3 field errors but got %v", result.GetPanic())
+	}
+
+	type typed struct {
+		Name   string `json:"name"`
+		Active bool   `json:"active"`
+		Note   string `json:"note"`
+		Raw    []byte `json:"raw"`
+		Flag   bool   `json:"flag"`
+	}
+	u = getTestContextWithBody("http://text.de/", ContentTypeJSON, `{"name": null, "active": true, "note": {"a": 1}, "raw": [1, 2], "flag": "yes"}`)
+	result = Bind[typed]()(u)
+	errs = nil
+	if !errors.As(result.GetPanic(), &errs) || len(errs) != 3 || errs[0].Field != "note" || errs[1].Field != "raw" || errs[2].Field != "flag" {
+		t.Errorf("Bind[typed]() should reject objects, arrays and invalid booleans but got %v", result.GetPanic())
+	}
+	u = getTestContextWithBody("http://text.de/", ContentTypeJSON, `{"name": null, "active": true}`)
+	tv, ok := BoundAs[typed](*Bind[typed]()(u))
+	if !ok || tv.Name != "" || !tv.Active {
+		t.Errorf("Bind[typed]() should leave null unset and accept JSON booleans but bound %+v", tv)
+	}
+}
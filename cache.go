@@ -0,0 +1,175 @@
+package grest
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+//CacheControl builds the value of a Cache-Control header, every method returns a copy with the directive added (or replaced)
+//e.g.: NewCacheControl().Public().MaxAge(time.Hour).StaleWhileRevalidate(time.Minute) => "public, max-age=3600, stale-while-revalidate=60"
+type CacheControl struct {
+	directives []cacheDirective
+}
+
+//NewCacheControl returns an empty CacheControl
+func NewCacheControl() CacheControl {
+	return CacheControl{}
+}
+
+//Public allows shared caches (proxies, CDNs) to store the response (removes private)
+func (c CacheControl) Public() CacheControl {
+	return c.without("private").with("public", "")
+}
+
+//Private only allows the browser cache to store the response (removes public)
+func (c CacheControl) Private() CacheControl {
+	return c.without("public").with("private", "")
+}
+
+//MaxAge is the time the response is fresh
+func (c CacheControl) MaxAge(d time.Duration) CacheControl {
+	return c.with("max-age", seconds(d))
+}
+
+//SharedMaxAge is the time the response is fresh in shared caches (s-maxage)
+func (c CacheControl) SharedMaxAge(d time.Duration) CacheControl {
+	return c.with("s-maxage", seconds(d))
+}
+
+//NoCache lets caches store the response but they have to revalidate it before every use
+func (c CacheControl) NoCache() CacheControl {
+	return c.with("no-cache", "")
+}
+
+//NoStore forbids all caches to store the response
+func (c CacheControl) NoStore() CacheControl {
+	return c.with("no-store", "")
+}
+
+//MustRevalidate forbids caches to use the response after it became stale without revalidating it
+func (c CacheControl) MustRevalidate() CacheControl {
+	return c.with("must-revalidate", "")
+}
+
+//Immutable tells that the response never changes while it is fresh (e.g. for assets with a content hash in their name)
+func (c CacheControl) Immutable() CacheControl {
+	return c.with("immutable", "")
+}
+
+//StaleWhileRevalidate allows caches to use a stale response for d while they revalidate it in the background
+func (c CacheControl) StaleWhileRevalidate(d time.Duration) CacheControl {
+	return c.with("stale-while-revalidate", seconds(d))
+}
+
+//StaleIfError allows caches to use a stale response for d if revalidating it fails
+func (c CacheControl) StaleIfError(d time.Duration) CacheControl {
+	return c.with("stale-if-error", seconds(d))
+}
+
+//String returns the value of the Cache-Control header with the directives in the order they were added
+func (c CacheControl) String() string {
+	parts := make([]string, len(c.directives))
+	for i, d := range c.directives {
+		parts[i] = d.name
+		if d.value != "" {
+			parts[i] += "=" + d.value
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+//Cache sets the Cache-Control header (replacing the value set before)
+func Cache(c CacheControl) WebPart {
+	return SetHeader(HeaderKeyCacheControl, c.String())
+}
+
+//Cache sets the Cache-Control header (replacing the value set before)
+func (w WebPart) Cache(c CacheControl) WebPart {
+	return Compose(w, Cache(c))
+}
+
+//NoCache sets "Cache-Control: no-cache", so caches have to revalidate the response before every use (see ETags())
+func NoCache() WebPart {
+	return Cache(NewCacheControl().NoCache())
+}
+
+//NoCache sets "Cache-Control: no-cache", so caches have to revalidate the response before every use (see ETags())
+func (w WebPart) NoCache() WebPart {
+	return Compose(w, NoCache())
+}
+
+//NoStore sets "Cache-Control: no-store", so the response is not stored by any cache (e.g. for personal data)
+func NoStore() WebPart {
+	return Cache(NewCacheControl().NoStore())
+}
+
+//NoStore sets "Cache-Control: no-store", so the response is not stored by any cache (e.g. for personal data)
+func (w WebPart) NoStore() WebPart {
+	return Compose(w, NoStore())
+}
+
+//CacheFor sets "Cache-Control: public, max-age=<d in seconds>"
+func CacheFor(d time.Duration) WebPart {
+	return Cache(NewCacheControl().Public().MaxAge(d))
+}
+
+//CacheFor sets "Cache-Control: public, max-age=<d in seconds>"
+func (w WebPart) CacheFor(d time.Duration) WebPart {
+	return Compose(w, CacheFor(d))
+}
+
+//Vary adds request header names to the Vary header, keeping the ones added before (e.g. by Negotiate or Compress)
+func Vary(keys ...string) WebPart {
+	return func(u WebUnit) *WebUnit {
+		AddVary(u.Writer.Header(), keys...)
+		return &u
+	}
+}
+
+//Vary adds request header names to the Vary header, keeping the ones added before (e.g. by Negotiate or Compress)
+func (w WebPart) Vary(keys ...string) WebPart {
+	return Compose(w, Vary(keys...))
+}
+
+//=== Helpers =====================================================================================
+
+//cacheDirective is a single directive of a CacheControl ("" = no value)
+type cacheDirective struct {
+	name, value string
+}
+
+//with returns a copy of c with the directive added or replaced
+func (c CacheControl) with(name, value string) CacheControl {
+	directives := make([]cacheDirective, 0, len(c.directives)+1)
+	replaced := false
+	for _, d := range c.directives {
+		if d.name == name {
+			d.value, replaced = value, true
+		}
+		directives = append(directives, d)
+	}
+	if !replaced {
+		directives = append(directives, cacheDirective{name, value})
+	}
+	return CacheControl{directives}
+}
+
+//without returns a copy of c without the directive
+func (c CacheControl) without(name string) CacheControl {
+	directives := make([]cacheDirective, 0, len(c.directives))
+	for _, d := range c.directives {
+		if d.name != name {
+			directives = append(directives, d)
+		}
+	}
+	return CacheControl{directives}
+}
+
+//seconds formats a duration as number of seconds for Cache-Control (negative durations are 0)
+func seconds(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
@@ -0,0 +1,40 @@
+package grest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCacheControl(t *testing.T) {
+	cases := []struct {
+		c        CacheControl
+		expected string
+	}{
+		{NewCacheControl(), ""},
+		{NewCacheControl().Public().MaxAge(time.Hour).StaleWhileRevalidate(time.Minute), "public, max-age=3600, stale-while-revalidate=60"},
+		{NewCacheControl().Public().Private().SharedMaxAge(90 * time.Second), "private, s-maxage=90"},
+		{NewCacheControl().MaxAge(time.Hour).MaxAge(time.Minute).MustRevalidate(), "max-age=60, must-revalidate"},
+		{NewCacheControl().NoStore().NoCache().StaleIfError(-time.Second), "no-store, no-cache, stale-if-error=0"},
+	}
+	for i, c := range cases {
+		if c.c.String() != c.expected {
+			t.Errorf("case %d should be %q but was %q", i, c.expected, c.c.String())
+		}
+	}
+
+	base := NewCacheControl().Public()
+	base.MaxAge(time.Hour)
+	if base.String() != "public" {
+		t.Errorf("builder methods should not modify the original but was %q", base.String())
+	}
+
+	u, w := getTestRecorder(http.MethodGet, "/", nil)
+	CacheFor(10*time.Minute).Vary("Accept", "Authorization").Compress().Negotiate("x", JSONEncoder()).Flush()(u)
+	if w.Header().Get(HeaderKeyCacheControl) != "public, max-age=600" {
+		t.Errorf("CacheFor should set Cache-Control but was %q", w.Header().Get(HeaderKeyCacheControl))
+	}
+	if vary := w.Header().Values(HeaderKeyVary); len(vary) != 3 || vary[0] != "Accept" || vary[1] != "Authorization" || vary[2] != HeaderKeyAcceptEncoding {
+		t.Errorf("Vary should be merged but was %v", vary)
+	}
+}
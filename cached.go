@@ -0,0 +1,342 @@
+package grest
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ResponseCache is a bounded in-memory LRU cache for complete responses (see Cached)
+type ResponseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	size       int64
+	lru        *list.List
+	entries    map[string]*list.Element
+	calls      map[string]*cacheCall
+}
+
+//NewResponseCache creates a ResponseCache that holds at most maxEntries responses with bodies of at most maxBytes in total (0 = unlimited)
+//The least recently used responses are removed first
+func NewResponseCache(maxEntries int, maxBytes int64) *ResponseCache {
+	return &ResponseCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+		calls:      map[string]*cacheCall{},
+	}
+}
+
+//DefaultResponseCache is used by Cached (1000 responses, 64MB)
+var DefaultResponseCache = NewResponseCache(1000, 64<<20)
+
+//Invalidate removes all responses whose key starts with prefix (e.g. "GET /reports" for all queries of /reports) and returns their number
+func (c *ResponseCache) Invalidate(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for key, element := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(element)
+			removed++
+		}
+	}
+	return removed
+}
+
+//Len returns the number of cached responses
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+//CacheKey returns a key function for Cached that uses method, path, query and the values of the given request headers
+//The keys look like "GET /reports?year=2020" followed by a line "Name: value" for every header
+func CacheKey(headers ...string) func(WebUnit) string {
+	return func(u WebUnit) string {
+		var key strings.Builder
+		key.WriteString(u.Request.Method + " " + u.Request.URL.Path)
+		if u.Request.URL.RawQuery != "" {
+			key.WriteString("?" + u.Request.URL.RawQuery)
+		}
+		for _, h := range headers {
+			key.WriteString("\n" + h + ": " + u.Request.Header.Get(h))
+		}
+		return key.String()
+	}
+}
+
+//Cached answers GET and HEAD requests with the response part created before, as long as it is not older than ttl (see DefaultResponseCache)
+//The responses are stored with status, body and the headers set by the wrapped WebPart (not the ones set before Cached) under the key returned by keyFunc (nil = CacheKey()), the request headers listed in Vary are compared as well.
+//Only successful (200) responses without Set-Cookie, Content-Encoding and "Cache-Control: no-store" or "private" are stored (use Compress() before Cached, responses compressed inside Cached are sent but not stored).
+//The response of the wrapped WebPart is recorded like with Buffered(), so Buffered() and Compress() can be used inside Cached.
+//The Cache-Control directives of the request are honoured: no-cache (create a new response), no-store (bypass the cache), max-age (maximum age of the response) and only-if-cached (504 if not cached).
+//Concurrent requests for the same key wait for the first one, so part only runs once
+func Cached(ttl time.Duration, keyFunc func(WebUnit) string, part WebPart) WebPart {
+	return CachedIn(DefaultResponseCache, ttl, keyFunc, part)
+}
+
+//Cached answers GET and HEAD requests with the response w created before, as long as it is not older than ttl (see DefaultResponseCache)
+//The responses are stored with status, body and the headers set by the wrapped WebPart (not the ones set before Cached) under the key returned by keyFunc (nil = CacheKey()), the request headers listed in Vary are compared as well.
+//Only successful (200) responses without Set-Cookie, Content-Encoding and "Cache-Control: no-store" or "private" are stored (use Compress() before Cached, responses compressed inside Cached are sent but not stored).
+//The response of the wrapped WebPart is recorded like with Buffered(), so Buffered() and Compress() can be used inside Cached.
+//The Cache-Control directives of the request are honoured: no-cache (create a new response), no-store (bypass the cache), max-age (maximum age of the response) and only-if-cached (504 if not cached).
+//Concurrent requests for the same key wait for the first one, so w only runs once
+func (w WebPart) Cached(ttl time.Duration, keyFunc func(WebUnit) string) WebPart {
+	return Cached(ttl, keyFunc, w)
+}
+
+//CachedIn works like Cached but stores the responses in cache
+func CachedIn(cache *ResponseCache, ttl time.Duration, keyFunc func(WebUnit) string, part WebPart) WebPart {
+	if keyFunc == nil {
+		keyFunc = CacheKey()
+	}
+	return func(u WebUnit) *WebUnit {
+		if (u.Request.Method != http.MethodGet && u.Request.Method != http.MethodHead) || u.GetPanic() != nil {
+			return part(u)
+		}
+		directives := parseCacheControl(u.Request.Header.Get(HeaderKeyCacheControl))
+		if _, ok := directives["no-store"]; ok {
+			return part(u)
+		}
+		key := keyFunc(u)
+		maxAge := time.Duration(-1)
+		if s, ok := directives["max-age"]; ok {
+			if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+				maxAge = time.Duration(n) * time.Second
+			}
+		}
+		if _, ok := directives["no-cache"]; !ok {
+			if e := cache.get(key, maxAge); e != nil && e.matches(u) {
+				return serveCached(u, e)
+			}
+		}
+		if _, ok := directives["only-if-cached"]; ok {
+			u.Panic(NewStatusError(http.StatusGatewayTimeout, "response is not cached"))
+			return servePanic(u)
+		}
+
+		call, leader := cache.join(key)
+		if !leader {
+			<-call.done
+			if e := call.entry; e != nil && e.matches(u) {
+				return serveCached(u, e)
+			}
+			return cache.record(u, key, ttl, part, nil)
+		}
+		return cache.record(u, key, ttl, part, call)
+	}
+}
+
+//CachedIn works like Cached but stores the responses in cache
+func (w WebPart) CachedIn(cache *ResponseCache, ttl time.Duration, keyFunc func(WebUnit) string) WebPart {
+	return CachedIn(cache, ttl, keyFunc, w)
+}
+
+//=== Helpers =====================================================================================
+
+//cachedResponse is a response stored in a ResponseCache
+type cachedResponse struct {
+	key    string
+	status int
+	header http.Header
+	body   []byte
+	//vary holds the values of the request headers listed in the Vary header of the response
+	vary    map[string]string
+	stored  time.Time
+	expires time.Time
+}
+
+//matches returns true if the request has the same values for the headers listed in Vary
+func (e *cachedResponse) matches(u WebUnit) bool {
+	for name, value := range e.vary {
+		if u.Request.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+//cacheCall is a running request other requests for the same key wait for
+type cacheCall struct {
+	done  chan struct{}
+	entry *cachedResponse
+}
+
+//get returns the fresh response for key that is not older than maxAge (-1 = any age) or nil
+func (c *ResponseCache) get(key string, maxAge time.Duration) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	e := element.Value.(*cachedResponse)
+	now := time.Now()
+	if now.After(e.expires) {
+		c.remove(element)
+		return nil
+	}
+	if maxAge >= 0 && now.Sub(e.stored) > maxAge {
+		return nil
+	}
+	c.lru.MoveToFront(element)
+	return e
+}
+
+//put stores e and removes the least recently used responses if the limits are exceeded
+func (c *ResponseCache) put(e *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxBytes > 0 && int64(len(e.body)) > c.maxBytes {
+		return
+	}
+	if element, ok := c.entries[e.key]; ok {
+		c.remove(element)
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.size += int64(len(e.body))
+	for (c.maxEntries > 0 && c.lru.Len() > c.maxEntries) || (c.maxBytes > 0 && c.size > c.maxBytes) {
+		c.remove(c.lru.Back())
+	}
+}
+
+//remove deletes a response (c.mu has to be locked)
+func (c *ResponseCache) remove(element *list.Element) {
+	e := c.lru.Remove(element).(*cachedResponse)
+	delete(c.entries, e.key)
+	c.size -= int64(len(e.body))
+}
+
+//join returns the running call for key or starts a new one (leader = true)
+func (c *ResponseCache) join(key string) (*cacheCall, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if call, ok := c.calls[key]; ok {
+		return call, false
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.calls[key] = call
+	return call, true
+}
+
+//record runs part with a recording writer, stores the response if possible and sends it
+//If call is not nil the waiting requests are released when part finished (even if it panics)
+func (c *ResponseCache) record(u WebUnit, key string, ttl time.Duration, part WebPart, call *cacheCall) *WebUnit {
+	var entry *cachedResponse
+	if call != nil {
+		defer func() {
+			c.mu.Lock()
+			delete(c.calls, key)
+			c.mu.Unlock()
+			call.entry = entry
+			close(call.done)
+		}()
+	}
+
+	//the recording is the Response of part, so Buffered() and Compress() inside part record into it as well
+	r := &Response{Body: &bytes.Buffer{}, writer: u.Writer, initial: u.Writer.Header().Clone()}
+	r.header = r.initial.Clone()
+	recording := u
+	recording.Writer = r
+	recording.Context = context.WithValue(u.Context, responseKey, r)
+	result := part(recording)
+	if result == nil {
+		return nil
+	}
+	if result.GetPanic() == nil && r.Written() {
+		//the response is stored as it is sent (e.g. compressed by Compress() inside part)
+		r.runHooks()
+	}
+	//only the headers part set belong to the response, the ones set before are specific to this request
+	header := changedHeader(r.initial, r.header)
+	if result.GetPanic() == nil && cacheable(r.Code, header) {
+		now := time.Now()
+		entry = &cachedResponse{key, r.Code, header, append([]byte{}, r.Body.Bytes()...), map[string]string{}, now, now.Add(ttl)}
+		for _, v := range header.Values(HeaderKeyVary) {
+			for _, name := range strings.Split(v, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					entry.vary[name] = u.Request.Header.Get(name)
+				}
+			}
+		}
+		c.put(entry)
+	}
+	if r.Written() {
+		result = Flush()(*result)
+	} else {
+		//nothing to send yet, the following WebParts continue with the headers part set
+		r.copyHeader()
+	}
+	result.Writer = u.Writer
+	//the following WebParts continue with the Response of u (nil if u is not buffered)
+	result.Context = context.WithValue(result.Context, responseKey, u.Response())
+	return result
+}
+
+//changedHeader returns the keys of header that were added or changed compared to initial
+func changedHeader(initial, header http.Header) http.Header {
+	changed := http.Header{}
+	for k, v := range header {
+		if before, ok := initial[k]; !ok || strings.Join(before, "\n") != strings.Join(v, "\n") {
+			changed[k] = append([]string{}, v...)
+		}
+	}
+	return changed
+}
+
+//cacheable returns true if a recorded response with the given status and header can be stored
+func cacheable(status int, header http.Header) bool {
+	if status != http.StatusOK || header.Get(HeaderKeySetCookie) != "" || header.Get(HeaderKeyContentEncoding) != "" {
+		return false
+	}
+	directives := parseCacheControl(header.Get(HeaderKeyCacheControl))
+	if _, ok := directives["no-store"]; ok {
+		return false
+	}
+	if _, ok := directives["private"]; ok {
+		return false
+	}
+	for _, v := range header.Values(HeaderKeyVary) {
+		if strings.TrimSpace(v) == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+//serveCached sends a stored response (or 304 if the client already has it)
+func serveCached(u WebUnit, e *cachedResponse) *WebUnit {
+	header := u.Writer.Header()
+	for k, v := range e.header {
+		header[k] = append([]string{}, v...)
+	}
+	header.Set(HeaderKeyAge, strconv.Itoa(int(time.Since(e.stored)/time.Second)))
+	if notModified(u) {
+		return serveNotModified(u)
+	}
+	header.Set(HeaderKeyContentLength, strconv.Itoa(len(e.body)))
+	u.Writer.WriteHeader(e.status)
+	u.Writer.Write(e.body)
+	return &u
+}
+
+//parseCacheControl returns the directives of a Cache-Control header with their values (lower case names, "" for directives without value)
+func parseCacheControl(header string) map[string]string {
+	directives := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			directives[name] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return directives
+}
@@ -0,0 +1,128 @@
+package grest
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCached(t *testing.T) {
+	cache := NewResponseCache(2, 0)
+	var calls int32
+	part := CachedIn(cache, time.Minute, nil, ServeBytesLazy(func(u WebUnit) ([]byte, error) {
+		n := atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return []byte("response " + strconv.Itoa(int(n))), nil
+	}))
+	serve := func(target string, header http.Header) *httptest.ResponseRecorder {
+		u, w := getTestRecorder(http.MethodGet, target, header)
+		part(u)
+		return w
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := serve("/a", nil); w.Body.String() != "response 1" {
+				t.Errorf("concurrent requests should share one response but got %q", w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("concurrent misses should run the part once but ran %d times", calls)
+	}
+
+	if w := serve("/a", nil); w.Body.String() != "response 1" || w.Header().Get(HeaderKeyAge) == "" {
+		t.Errorf("hit should be served from the cache with Age but was %q %v", w.Body.String(), w.Header())
+	}
+	if w := serve("/a", http.Header{HeaderKeyCacheControl: {"no-cache"}}); w.Body.String() != "response 2" {
+		t.Errorf("no-cache should create a new response but was %q", w.Body.String())
+	}
+	if w := serve("/a", nil); w.Body.String() != "response 2" {
+		t.Errorf("no-cache response should be stored but was %q", w.Body.String())
+	}
+	if w := serve("/b", http.Header{HeaderKeyCacheControl: {"only-if-cached"}}); w.Code != http.StatusGatewayTimeout {
+		t.Errorf("only-if-cached should be 504 on a miss but was %d", w.Code)
+	}
+
+	serve("/b?x=1", nil)
+	serve("/c", nil)
+	if cache.Len() != 2 {
+		t.Errorf("cache should be bounded to 2 entries but has %d", cache.Len())
+	}
+	if n := cache.Invalidate("GET /b"); n != 1 || cache.Len() != 1 {
+		t.Errorf("Invalidate should remove 1 entry but removed %d (%d left)", n, cache.Len())
+	}
+
+	before := atomic.LoadInt32(&calls)
+	cookies := CachedIn(cache, time.Minute, nil, SetHeader(HeaderKeySetCookie, "a=b").ServeString("private"))
+	for i := 0; i < 2; i++ {
+		u, _ := getTestRecorder(http.MethodGet, "/cookie", nil)
+		cookies(u)
+	}
+	if cache.Len() != 1 || atomic.LoadInt32(&calls) != before {
+		t.Errorf("responses with Set-Cookie should not be stored")
+	}
+
+	//headers set before Cached belong to the request and are not replayed
+	cache = NewResponseCache(10, 0)
+	withID := func(id string) WebPart {
+		return Compose(SetHeader("X-Request-Id", id), CachedIn(cache, time.Minute, nil, SetHeader("X-From-Part", "1").ServeString("shared")))
+	}
+	for _, id := range []string{"req-1", "req-2"} {
+		u, w := getTestRecorder(http.MethodGet, "/shared", nil)
+		withID(id)(u)
+		if w.Header().Get("X-Request-Id") != id || w.Header().Get("X-From-Part") != "1" || w.Body.String() != "shared" {
+			t.Errorf("%s should keep its own headers and get the cached ones but was %v", id, w.Header())
+		}
+	}
+
+	//headers of a part that writes nothing are kept for the following WebParts
+	u, w := getTestRecorder(http.MethodGet, "/later", nil)
+	Compose(CachedIn(cache, time.Minute, nil, SetHeader("X-From-Part", "1")), ServeString("after"))(u)
+	if w.Header().Get("X-From-Part") != "1" || w.Body.String() != "after" {
+		t.Errorf("headers of a part without body should be kept but was %v %q", w.Header(), w.Body.String())
+	}
+
+	//Buffered inside Cached records into the cached response
+	buffered := CachedIn(cache, time.Minute, nil, Buffered().ServeString("hello"))
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router{buffered}.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/buffered", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "hello" {
+			t.Errorf("request %d of a buffered part should be sent but was %d %q", i, w.Code, w.Body.String())
+		}
+	}
+
+	//Compress inside Cached sends a complete stream with a matching Content-Length
+	text := strings.Repeat("x", 5000)
+	compressed := CachedIn(cache, time.Minute, nil, Compress().ServeBytes([]byte(text)))
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/compressed", nil)
+		r.Header.Set(HeaderKeyAcceptEncoding, "gzip")
+		router{compressed}.ServeHTTP(w, r)
+		if w.Header().Get(HeaderKeyContentEncoding) != "gzip" || w.Header().Get(HeaderKeyContentLength) != strconv.Itoa(w.Body.Len()) {
+			t.Errorf("request %d should be gzip with a matching Content-Length but was %v (%d bytes)", i, w.Header(), w.Body.Len())
+			continue
+		}
+		gr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Errorf("request %d: %v", i, err)
+			continue
+		}
+		if data, err := ioutil.ReadAll(gr); err != nil || string(data) != text {
+			t.Errorf("request %d should decompress to the text but was %v", i, err)
+		}
+	}
+}
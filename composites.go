@@ -5,11 +5,14 @@ package grest
 // and the first WebPart that statisfies is executed and returned
 func Choose(options ...WebPart) WebPart {
 	return func(unit WebUnit) *WebUnit {
-		for _, c := range options {
-			result := c(unit)
+		for i, c := range options {
+			mark := deferMark(unit)
+			result := traced("Choose", i, c, unit)
 			if result != nil {
 				return result
 			}
+			//the cleanup of a rejected option must not wait for the end of the request
+			rollbackDeferred(unit, mark)
 		}
 		return nil
 	}
@@ -24,12 +27,15 @@ func Compose(parts ...WebPart) WebPart {
 	return func(unit WebUnit) *WebUnit {
 		var result *WebUnit
 		next := unit
-		for _, p := range parts {
+		for i, p := range parts {
 			if p == nil {
 				break
 			}
 			result = p(next)
 			if result == nil {
+				if t := next.GetTrace(); t != nil {
+					t.note("rejected by Compose part %d", i)
+				}
 				break
 			}
 
@@ -0,0 +1,277 @@
+package grest
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//compressKey to save the compression settings of Compress() in the context
+const compressKey contextKey = "compress"
+
+//MinCompressSize is the default size in bytes below which responses are not compressed
+const MinCompressSize = 1024
+
+//CompressOptions configure the response compression of CompressWith
+type CompressOptions struct {
+	//Level of the gzip/deflate compression (0 = gzip.DefaultCompression)
+	Level int
+	//MinSize in bytes, responses with a known smaller size are sent uncompressed (0 = MinCompressSize)
+	MinSize int64
+	//Precompressed lets ServeFile serve "<file>.gz" with "Content-Encoding: gzip" if it exists and the client accepts gzip
+	Precompressed bool
+}
+
+//Compress compresses the responses of the following WebParts with gzip or deflate, whichever fits the Accept-Encoding header of the request best.
+//Already compressed types (images, audio, video, archives, ...), responses smaller than MinCompressSize and responses that already have a Content-Encoding are sent as they are.
+//ServeFile serves precompressed "<file>.gz" siblings if they exist (see CompressOptions.Precompressed)
+func Compress() WebPart {
+	return CompressWith(CompressOptions{Precompressed: true})
+}
+
+//Compress compresses the responses of the following WebParts with gzip or deflate, whichever fits the Accept-Encoding header of the request best.
+//Already compressed types (images, audio, video, archives, ...), responses smaller than MinCompressSize and responses that already have a Content-Encoding are sent as they are.
+//ServeFile serves precompressed "<file>.gz" siblings if they exist (see CompressOptions.Precompressed)
+func (w WebPart) Compress() WebPart {
+	return Compose(w, Compress())
+}
+
+//CompressWith works like Compress() with the given options
+//If the response is buffered (see Buffered()) the recorded body is compressed when it is flushed, so CompressWith can also follow the serving WebPart.
+//If the WebUnit was not created by the router or NewWebUnit (see WebUnit.Defer) the response is buffered and has to be sent with Flush()
+func CompressWith(options CompressOptions) WebPart {
+	return func(u WebUnit) *WebUnit {
+		if u.Context.Value(compressKey) != nil {
+			return &u
+		}
+		c := &compression{options, acceptEncoding(u.Request.Header.Get(HeaderKeyAcceptEncoding), "gzip", "deflate")}
+		u.Context = context.WithValue(u.Context, compressKey, c)
+		if r := u.Response(); r != nil && !r.Sent() {
+			r.beforeSend(c.compressResponse)
+			return &u
+		}
+		cw := &compressWriter{ResponseWriter: u.Writer, c: c}
+		if err := u.Defer(cw.close); err != nil {
+			//the end of the stream could never be written, so the response is buffered and compressed on Flush() instead
+			b := Buffered()(u)
+			b.Response().beforeSend(c.compressResponse)
+			return b
+		}
+		u.Writer = cw
+		return &u
+	}
+}
+
+//CompressWith works like Compress() with the given options
+//If the response is buffered (see Buffered()) the recorded body is compressed when it is flushed, so CompressWith can also follow the serving WebPart.
+//If the WebUnit was not created by the router or NewWebUnit (see WebUnit.Defer) the response is buffered and has to be sent with Flush()
+func (w WebPart) CompressWith(options CompressOptions) WebPart {
+	return Compose(w, CompressWith(options))
+}
+
+//=== Helpers =====================================================================================
+
+//compression holds the options of CompressWith and the encoding negotiated for the request ("" = no acceptable encoding)
+type compression struct {
+	options  CompressOptions
+	encoding string
+}
+
+//decide returns true if a response with the given header, status and size (-1 = unknown) should be compressed and prepares its header
+//Vary: Accept-Encoding is added to every response that depends on the Accept-Encoding of the request
+func (c *compression) decide(header http.Header, status int, size int64) bool {
+	if !bodyAllowed(status) || status == http.StatusPartialContent || header.Get(HeaderKeyContentEncoding) != "" || !compressibleType(header.Get(HeaderKeyContentType)) {
+		return false
+	}
+	AddVary(header, HeaderKeyAcceptEncoding)
+	minSize := c.options.MinSize
+	if minSize <= 0 {
+		minSize = MinCompressSize
+	}
+	if c.encoding == "" || (size >= 0 && size < minSize) {
+		return false
+	}
+	header.Del(HeaderKeyContentLength)
+	header.Set(HeaderKeyContentEncoding, c.encoding)
+	//the compressed bytes differ from the ones a strong ETag was computed for
+	if etag := header.Get(HeaderKeyETag); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set(HeaderKeyETag, "W/"+etag)
+	}
+	return true
+}
+
+//writer returns a compressing writer for the negotiated encoding
+func (c *compression) writer(w io.Writer) io.WriteCloser {
+	level := c.options.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if c.encoding == "deflate" {
+		if fw, err := flate.NewWriter(w, level); err == nil {
+			return fw
+		}
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	}
+	if gw, err := gzip.NewWriterLevel(w, level); err == nil {
+		return gw
+	}
+	return gzip.NewWriter(w)
+}
+
+//compressResponse compresses the body of a buffered response
+func (c *compression) compressResponse(r *Response) {
+	if !c.decide(r.Header(), r.Code, int64(r.Body.Len())) {
+		return
+	}
+	var b bytes.Buffer
+	w := c.writer(&b)
+	w.Write(r.Body.Bytes())
+	w.Close()
+	r.Body = &b
+}
+
+//compressWriter compresses everything written to it if compression.decide() allows it when the header is written
+type compressWriter struct {
+	http.ResponseWriter
+	c           *compression
+	w           io.WriteCloser
+	wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		size := int64(-1)
+		if parsed, err := strconv.ParseInt(cw.Header().Get(HeaderKeyContentLength), 10, 64); err == nil {
+			size = parsed
+		}
+		if cw.c.decide(cw.Header(), status, size) {
+			cw.w = cw.c.writer(cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(data []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.w != nil {
+		return cw.w.Write(data)
+	}
+	return cw.ResponseWriter.Write(data)
+}
+
+//Flush sends the data compressed so far to the client (see http.Flusher)
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := cw.w.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//close writes the end of the compressed stream
+func (cw *compressWriter) close() {
+	if cw.w != nil {
+		cw.w.Close()
+		cw.w = nil
+	}
+}
+
+//acceptEncoding returns the offered encoding with the highest q-value in the Accept-Encoding header or "" if none is acceptable (the first offer wins on equal q-values)
+func acceptEncoding(header string, offers ...string) string {
+	qs := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			if k, v, ok := strings.Cut(strings.TrimSpace(f), "="); ok && strings.TrimSpace(k) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		qs[name] = q
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, ok := qs[offer]
+		if !ok && offer == "gzip" {
+			q, ok = qs["x-gzip"]
+		}
+		if !ok {
+			q = qs["*"]
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+//compressedTypes are media types that are compressed already
+var compressedTypes = map[string]bool{
+	"application/zip":              true,
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/x-bzip2":          true,
+	"application/x-xz":             true,
+	"application/x-7z-compressed":  true,
+	"application/x-rar-compressed": true,
+	"application/zstd":             true,
+	"application/pdf":              true,
+	"font/woff":                    true,
+	"font/woff2":                   true,
+}
+
+//compressibleType returns false for media types that do not benefit from compression
+func compressibleType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	if mt == "image/svg+xml" {
+		return true
+	}
+	return !compressedTypes[mt] && !strings.HasPrefix(mt, "image/") && !strings.HasPrefix(mt, "video/") && !strings.HasPrefix(mt, "audio/")
+}
+
+//openPrecompressed opens "<name>.gz" of fsys if Compress() allows precompressed files and the client accepts gzip
+//If the file exists the headers for the gzip encoding are set, otherwise nil is returned
+func openPrecompressed(u WebUnit, fsys fs.FS, name string) fs.File {
+	c, ok := u.Context.Value(compressKey).(*compression)
+	if !ok || !c.options.Precompressed || acceptEncoding(u.Request.Header.Get(HeaderKeyAcceptEncoding), "gzip") == "" {
+		return nil
+	}
+	f, err := fsys.Open(name + ".gz")
+	if err != nil {
+		return nil
+	}
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		f.Close()
+		return nil
+	}
+	AddVary(u.Writer.Header(), HeaderKeyAcceptEncoding)
+	u.Writer.Header().Set(HeaderKeyContentEncoding, "gzip")
+	return f
+}
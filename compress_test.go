@@ -0,0 +1,91 @@
+package grest
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	text := strings.Repeat("grest compresses this text ", 100)
+	cases := []struct {
+		acceptEncoding string
+		part           WebPart
+		encoding       string
+	}{
+		{"gzip, deflate", Compress().ServeString(text), "gzip"},
+		{"gzip;q=0.5, deflate", Compress().ServeString(text), "deflate"},
+		{"br", Compress().ServeString(text), ""},
+		{"", Compress().ServeString(text), ""},
+		{"gzip", Compress().ServeString("tiny"), ""},
+		{"gzip", Compress().ContentType("image/png").ServeString(text), ""},
+		{"gzip", Buffered().ServeString(text).Compress(), "gzip"},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderKeyAcceptEncoding, c.acceptEncoding)
+		router{c.part}.ServeHTTP(w, r)
+		if w.Header().Get(HeaderKeyContentEncoding) != c.encoding {
+			t.Errorf("case %d should be encoded with %q but was %q", i, c.encoding, w.Header().Get(HeaderKeyContentEncoding))
+			continue
+		}
+		if c.encoding == "gzip" {
+			gr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Errorf("case %d: %v", i, err)
+				continue
+			}
+			data, err := ioutil.ReadAll(gr)
+			if err != nil || string(data) != text {
+				t.Errorf("case %d should decompress to the text but was %v", i, err)
+			}
+		}
+		if c.encoding == "" && w.Header().Get(HeaderKeyContentLength) == "" {
+			t.Errorf("case %d uncompressed response should keep its Content-Length", i)
+		}
+	}
+
+	//without deferred functions the response is buffered, so the stream is complete after Flush()
+	u, w := getTestRecorder(http.MethodGet, "/", http.Header{HeaderKeyAcceptEncoding: {"gzip"}})
+	Compress().ServeString(text).Flush()(u)
+	if gr, err := gzip.NewReader(w.Body); err != nil {
+		t.Errorf("Compress() without router should send gzip but was %v", err)
+	} else if data, err := ioutil.ReadAll(gr); err != nil || string(data) != text {
+		t.Errorf("Compress() without router should send a complete stream but was %v", err)
+	}
+
+	//flushing before the first write still decides about the compression
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderKeyAcceptEncoding, "gzip")
+	router{Compose(Compress().ContentType(ContentTypeText), func(u WebUnit) *WebUnit {
+		u.Writer.(http.Flusher).Flush()
+		u.Writer.Write([]byte(text))
+		return &u
+	})}.ServeHTTP(w, r)
+	if header := w.Result().Header; header.Get(HeaderKeyContentEncoding) != "gzip" {
+		t.Errorf("Flush() before the first write should send Content-Encoding gzip but was %v", header)
+	} else if gr, err := gzip.NewReader(w.Body); err != nil {
+		t.Errorf("flushed stream should be gzip but was %v", err)
+	} else if data, err := ioutil.ReadAll(gr); err != nil || string(data) != text {
+		t.Errorf("flushed stream should decompress to the text but was %v", err)
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.js")
+	os.WriteFile(file, []byte(text), 0644)
+	os.WriteFile(file+".gz", []byte("precompressed"), 0644)
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	r.Header.Set(HeaderKeyAcceptEncoding, "gzip")
+	router{Compress().ServeFile(file)}.ServeHTTP(w, r)
+	if w.Body.String() != "precompressed" || w.Header().Get(HeaderKeyContentEncoding) != "gzip" || !strings.Contains(w.Header().Get(HeaderKeyContentType), "javascript") || w.Header().Get(HeaderKeyVary) != HeaderKeyAcceptEncoding {
+		t.Errorf("precompressed sibling should be served but was %q %v", w.Body.String(), w.Header())
+	}
+}
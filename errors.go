@@ -0,0 +1,50 @@
+package grest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+//StatusError is an error that knows with which HTTP status it should be answered.
+//Put it into the panic context of a WebUnit with Panic(...) and the serving WebParts respond with its Status instead of 500
+type StatusError struct {
+	Status int
+	Err    error
+}
+
+//Error returns the message of the wrapped error
+func (e StatusError) Error() string {
+	if e.Err == nil {
+		return http.StatusText(e.Status)
+	}
+	return e.Err.Error()
+}
+
+//Unwrap returns the wrapped error
+func (e StatusError) Unwrap() error {
+	return e.Err
+}
+
+//NewStatusError creates a StatusError with a formatted message
+func NewStatusError(status int, format string, args ...interface{}) error {
+	return StatusError{Status: status, Err: fmt.Errorf(format, args...)}
+}
+
+//ErrorStatus returns the HTTP status an error should be answered with: the Status of a StatusError in its chain or 500
+//Returns 0 for nil
+func ErrorStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var se StatusError
+	if errors.As(err, &se) {
+		return se.Status
+	}
+	return http.StatusInternalServerError
+}
+
+//GetPanicStatus returns the HTTP status of the current panic (see ErrorStatus) or 0 if there is none
+func (u WebUnit) GetPanicStatus() int {
+	return ErrorStatus(u.GetPanic())
+}
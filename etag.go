@@ -0,0 +1,190 @@
+package grest
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//etagKey to save the ETag mode of ETags() in the context
+const etagKey contextKey = "etag"
+
+//ETags lets the following serving WebParts (ServeBytes, ServeJSON, ServeFile, ...) set an ETag header and answer conditional GET requests with 304 (Not Modified).
+//The ETag of files is computed from their modification time and size (they also get a Last-Modified header), the ETag of other content and files without modification time is a hash of the content.
+//ReadClosers are only hashed if they are not larger than ResponseBufferSize. With weak = true weak ETags (W/"...") are used.
+//ETags that were set before (e.g. with SetHeader) are kept
+func ETags(weak bool) WebPart {
+	return func(u WebUnit) *WebUnit {
+		u.Context = context.WithValue(u.Context, etagKey, weak)
+		return &u
+	}
+}
+
+//ETags lets the following serving WebParts (ServeBytes, ServeJSON, ServeFile, ...) set an ETag header and answer conditional GET requests with 304 (Not Modified).
+//The ETag of files is computed from their modification time and size (they also get a Last-Modified header), the ETag of other content and files without modification time is a hash of the content.
+//ReadClosers are only hashed if they are not larger than ResponseBufferSize. With weak = true weak ETags (W/"...") are used.
+//ETags that were set before (e.g. with SetHeader) are kept
+func (w WebPart) ETags(weak bool) WebPart {
+	return Compose(w, ETags(weak))
+}
+
+//LastModified sets the Last-Modified header.
+//The following serving WebParts answer requests with a matching If-Modified-Since with 304 (Not Modified) before their content is created,
+//so ServeBytesLazy(...) does not need to generate content the client already has
+func LastModified(t time.Time) WebPart {
+	return SetHeader(HeaderKeyLastModified, t.UTC().Format(http.TimeFormat))
+}
+
+//LastModified sets the Last-Modified header.
+//The following serving WebParts answer requests with a matching If-Modified-Since with 304 (Not Modified) before their content is created,
+//so ServeBytesLazy(...) does not need to generate content the client already has
+func (w WebPart) LastModified(t time.Time) WebPart {
+	return Compose(w, LastModified(t))
+}
+
+//=== Helpers =====================================================================================
+
+//etagMode returns if ETags() is active and if weak ETags should be used
+func etagMode(u WebUnit) (enabled, weak bool) {
+	weak, enabled = u.Context.Value(etagKey).(bool)
+	return enabled, weak
+}
+
+//formatETag quotes tag and marks it as weak if necessary
+func formatETag(tag string, weak bool) string {
+	if weak {
+		return `W/"` + tag + `"`
+	}
+	return `"` + tag + `"`
+}
+
+//setValidators sets ETag and Last-Modified of the content if ETags() is active and they were not set before
+func (c *content) setValidators(u WebUnit) {
+	enabled, weak := etagMode(u)
+	if !enabled {
+		return
+	}
+	header := u.Writer.Header()
+	if !c.modTime.IsZero() {
+		if header.Get(HeaderKeyLastModified) == "" {
+			header.Set(HeaderKeyLastModified, c.modTime.UTC().Format(http.TimeFormat))
+		}
+		if header.Get(HeaderKeyETag) == "" && c.size >= 0 {
+			header.Set(HeaderKeyETag, formatETag(fmt.Sprintf("%x-%x", c.modTime.UnixNano(), c.size), weak))
+		}
+	}
+	if c.data != nil {
+		setHashETag(u, c.data)
+	} else if rs := seekerOf(c.reader); rs != nil && c.hashes != nil && c.modTime.IsZero() && c.size >= 0 && header.Get(HeaderKeyETag) == "" {
+		//fs files without modification time (e.g. in an embed.FS) are hashed once, other readers are hashed by serveContent if they are small enough
+		key := hashKey{c.name, header.Get(HeaderKeyContentEncoding), c.size}
+		tag, ok := c.hashes.get(key)
+		if !ok {
+			hash := sha256.New()
+			n, err := io.Copy(hash, rs)
+			if err != nil {
+				return
+			}
+			if _, err := rs.Seek(-n, io.SeekCurrent); err != nil {
+				return
+			}
+			tag = fmt.Sprintf("%x", hash.Sum(nil)[:16])
+			c.hashes.put(key, tag)
+		}
+		header.Set(HeaderKeyETag, formatETag(tag, weak))
+	}
+}
+
+//hashCache remembers the content hashes of files without modification time, which are expected to be immutable (like in an embed.FS)
+type hashCache struct {
+	hashes sync.Map
+}
+
+//hashKey identifies a file in a hashCache (the size is part of the key to notice replaced files)
+type hashKey struct {
+	name     string
+	encoding string
+	size     int64
+}
+
+//get returns the remembered hash of a file
+func (h *hashCache) get(key hashKey) (string, bool) {
+	tag, ok := h.hashes.Load(key)
+	if !ok {
+		return "", false
+	}
+	return tag.(string), true
+}
+
+//put remembers the hash of a file
+func (h *hashCache) put(key hashKey, tag string) {
+	h.hashes.Store(key, tag)
+}
+
+//setHashETag sets an ETag computed from data if ETags() is active and no ETag was set before
+func setHashETag(u WebUnit, data []byte) {
+	enabled, weak := etagMode(u)
+	if !enabled || u.Writer.Header().Get(HeaderKeyETag) != "" {
+		return
+	}
+	sum := sha256.Sum256(data)
+	u.Writer.Header().Set(HeaderKeyETag, formatETag(fmt.Sprintf("%x", sum[:16]), weak))
+}
+
+//notModified returns true if the client already has the representation described by the ETag and Last-Modified header of the response (only for GET and HEAD with status 200)
+//If-None-Match is evaluated with the weak comparison, If-Modified-Since is only evaluated without If-None-Match
+func notModified(u WebUnit) bool {
+	if u.Request.Method != http.MethodGet && u.Request.Method != http.MethodHead {
+		return false
+	}
+	if status := u.GetStatus(); status != 0 && status != http.StatusOK {
+		return false
+	}
+	header := u.Writer.Header()
+	if inm := u.Request.Header.Get(HeaderKeyIfNoneMatch); inm != "" {
+		etag := header.Get(HeaderKeyETag)
+		return etag != "" && etagMatches(inm, etag, false)
+	}
+	ims, err := http.ParseTime(u.Request.Header.Get(HeaderKeyIfModifiedSince))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(header.Get(HeaderKeyLastModified))
+	return err == nil && !lastModified.After(ims)
+}
+
+//serveNotModified responds with 304 (Not Modified) keeping the validator and caching headers
+func serveNotModified(u WebUnit) *WebUnit {
+	header := u.Writer.Header()
+	header.Del(HeaderKeyContentType)
+	header.Del(HeaderKeyContentLength)
+	u.Writer.WriteHeader(http.StatusNotModified)
+	return &u
+}
+
+//etagMatches returns true if etag is in the list of ETags (or the list is "*")
+//With strong = true weak ETags never match (strong comparison), otherwise the W/ prefix is ignored
+func etagMatches(list, etag string, strong bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strong && strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -1,33 +1,428 @@
 package grest
 
 import (
+	"bytes"
 	"fmt"
-	"io"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
 	"os"
+	pathpkg "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 )
 
 //ServeFile tries to serve the file
+//The Content-Type is detected by the file extension or the first bytes of the file if it was not set before (see ContentType(...))
+//After Compress() a precompressed "<file>.gz" is served instead if it exists and the client accepts gzip
+//Range requests (also with multiple ranges and If-Range) are answered with 206 (Partial Content) or 416 (Requested Range Not Satisfiable)
+//If the file cannot be opened the WebUnit panics with 404 (the reason, e.g. missing permissions, is not part of the response but can be found with errors.Is/As)
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
 func ServeFile(file string) WebPart {
-	return func(u WebUnit) *WebUnit {
-		return ServeReadCloser(func(WebUnit) (io.ReadCloser, error) {
-			return os.Open(file)
-		})(u)
-	}
+	return ServeFSFile(os.DirFS(filepath.Dir(file)), filepath.Base(file))
 }
 
 //ServeFile tries to serve the file
+//The Content-Type is detected by the file extension or the first bytes of the file if it was not set before (see ContentType(...))
+//After Compress() a precompressed "<file>.gz" is served instead if it exists and the client accepts gzip
+//Range requests (also with multiple ranges and If-Range) are answered with 206 (Partial Content) or 416 (Requested Range Not Satisfiable)
+//If the file cannot be opened the WebUnit panics with 404 (the reason, e.g. missing permissions, is not part of the response but can be found with errors.Is/As)
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
 func (w WebPart) ServeFile(file string) WebPart {
 	return Compose(w, ServeFile(file))
 }
 
+//ServeFSFile serves the file name of fsys (e.g. an embed.FS, os.DirFS or fstest.MapFS) like ServeFile
+//Without modification time (like in an embed.FS) the ETag of ETags() is a hash of the content, it is computed once per file because such files are expected to be immutable
+func ServeFSFile(fsys fs.FS, name string) WebPart {
+	return serveFSFile(fsys, name, &hashCache{})
+}
+
+//ServeFSFile serves the file name of fsys (e.g. an embed.FS, os.DirFS or fstest.MapFS) like ServeFile
+//Without modification time (like in an embed.FS) the ETag of ETags() is a hash of the content, it is computed once per file because such files are expected to be immutable
+func (w WebPart) ServeFSFile(fsys fs.FS, name string) WebPart {
+	return Compose(w, ServeFSFile(fsys, name))
+}
+
+//FolderOptions configure which files ServeFolderWith, ServeFSWith and ServeFileIn deliver
+//Paths are always resolved inside the folder, "../" cannot leave it
+type FolderOptions struct {
+	//AllowSymlinks allows symlinks that point outside of the folder (symlinks that stay inside are always allowed, only for folders on disk)
+	AllowSymlinks bool
+	//ShowDotfiles serves and lists files and folders whose name starts with "."
+	ShowDotfiles bool
+}
+
+//ServeFileIn serves the file with the slash separated path name inside the folder root like ServeFile (e.g. with a name taken from the URL)
+//Paths leaving the folder, symlinks pointing outside and dotfiles are rejected with 404 (see FolderOptions)
+func ServeFileIn(root, name string, options FolderOptions) WebPart {
+	return ServeFSFile(sandboxFS{root, options}, name)
+}
+
+//ServeFileIn serves the file with the slash separated path name inside the folder root like ServeFile (e.g. with a name taken from the URL)
+//Paths leaving the folder, symlinks pointing outside and dotfiles are rejected with 404 (see FolderOptions)
+func (w WebPart) ServeFileIn(root, name string, options FolderOptions) WebPart {
+	return Compose(w, ServeFileIn(root, name, options))
+}
+
+//FolderEntry is a file or folder in the listing of ServeFolder (the JSON listing is an array of FolderEntry)
+type FolderEntry struct {
+	Name     string    `json:"name"`
+	Dir      bool      `json:"dir"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
 //ServeFolder serves a folder overview similar to Pythons `python -m http.server`, where you would see an overview of the files in the folder and can navigate in it opening files that are served with ServeFile(...)
+//The request path is resolved inside the folder (use StripPrefix to serve the folder below a path). Folders are listed with folders first and names sorted alphabetically,
+//clients accepting JSON but not HTML get the listing as JSON array of FolderEntry. If a folder contains an index.html it is served instead of the listing.
+//Folders requested without trailing slash are redirected (301) to the path with trailing slash, so relative links work.
+//Dotfiles and symlinks pointing outside of the folder are hidden, missing or inaccessible files result in a panic with 404 (see ServeFolderWith)
 func ServeFolder(path string) WebPart {
-	return func(u WebUnit) *WebUnit {
-		return Panic(fmt.Errorf("ServeFolder is not implemented yet"))(u)
-	}
+	return ServeFolderWith(path, FolderOptions{})
 }
 
 //ServeFolder serves a folder overview similar to Pythons `python -m http.server`, where you would see an overview of the files in the folder and can navigate in it opening files that are served with ServeFile(...)
+//The request path is resolved inside the folder (use StripPrefix to serve the folder below a path). Folders are listed with folders first and names sorted alphabetically,
+//clients accepting JSON but not HTML get the listing as JSON array of FolderEntry. If a folder contains an index.html it is served instead of the listing.
+//Folders requested without trailing slash are redirected (301) to the path with trailing slash, so relative links work.
+//Dotfiles and symlinks pointing outside of the folder are hidden, missing or inaccessible files result in a panic with 404 (see ServeFolderWith)
 func (w WebPart) ServeFolder(path string) WebPart {
 	return Compose(w, ServeFolder(path))
 }
+
+//ServeFolderWith works like ServeFolder with the given options
+func ServeFolderWith(path string, options FolderOptions) WebPart {
+	return serveFolder(sandboxFS{path, options}, options)
+}
+
+//ServeFolderWith works like ServeFolder with the given options
+func (w WebPart) ServeFolderWith(path string, options FolderOptions) WebPart {
+	return Compose(w, ServeFolderWith(path, options))
+}
+
+//ServeFS serves the folder root of fsys (e.g. an embed.FS, os.DirFS, a zip.Reader or fstest.MapFS) like ServeFolder
+//The files are served with ServeFSFile, so they get the same Content-Type, ETag and range handling as files on disk
+func ServeFS(fsys fs.FS, root string) WebPart {
+	return ServeFSWith(fsys, root, FolderOptions{})
+}
+
+//ServeFS serves the folder root of fsys (e.g. an embed.FS, os.DirFS, a zip.Reader or fstest.MapFS) like ServeFolder
+//The files are served with ServeFSFile, so they get the same Content-Type, ETag and range handling as files on disk
+func (w WebPart) ServeFS(fsys fs.FS, root string) WebPart {
+	return Compose(w, ServeFS(fsys, root))
+}
+
+//ServeFSWith works like ServeFS with the given options
+func ServeFSWith(fsys fs.FS, root string, options FolderOptions) WebPart {
+	sub, err := fs.Sub(fsys, fsName(root))
+	if err != nil {
+		return Panic(err)
+	}
+	return serveFolder(sub, options)
+}
+
+//ServeFSWith works like ServeFS with the given options
+func (w WebPart) ServeFSWith(fsys fs.FS, root string, options FolderOptions) WebPart {
+	return Compose(w, ServeFSWith(fsys, root, options))
+}
+
+//=== Helpers =====================================================================================
+
+//contentTypeHTMLUTF8 is used for HTML responses
+const contentTypeHTMLUTF8 = ContentTypeHTML + "; charset=utf-8"
+
+//serveFSFile serves the file name of fsys (see ServeFSFile), the ETags of files without modification time are remembered in hashes
+func serveFSFile(fsys fs.FS, name string, hashes *hashCache) WebPart {
+	return serveContent(func(u WebUnit) (*content, error) {
+		name := fsName(name)
+		f := openPrecompressed(u, fsys, name)
+		if f == nil {
+			var err error
+			if f, err = fsys.Open(name); err != nil {
+				return nil, fileError(pathpkg.Base(name), err)
+			}
+		}
+		info, err := f.Stat()
+		if err == nil && info.IsDir() {
+			err = fs.ErrNotExist
+		}
+		if err != nil {
+			f.Close()
+			return nil, fileError(pathpkg.Base(name), err)
+		}
+		c := &content{reader: f, name: name, size: -1, modTime: info.ModTime(), hashes: hashes}
+		if info.Mode().IsRegular() {
+			c.size = info.Size()
+		}
+		return c, nil
+	})
+}
+
+//serveFolder serves the file or folder of fsys the request path points to (see ServeFolder)
+func serveFolder(fsys fs.FS, options FolderOptions) WebPart {
+	hashes := &hashCache{}
+	return func(u WebUnit) *WebUnit {
+		urlPath := u.Request.URL.Path
+		name := fsName(urlPath)
+		info, err := fs.Stat(fsys, name)
+		if err == nil && !options.ShowDotfiles && isDotfile(name) {
+			err = fs.ErrNotExist
+		}
+		trailingSlash := strings.HasSuffix(requestPath(u), "/")
+		if err == nil && !info.IsDir() && trailingSlash {
+			err = fs.ErrNotExist
+		}
+		if err != nil {
+			u.Panic(fileError(pathpkg.Clean("/"+urlPath), err))
+			return servePanic(u)
+		}
+		if !info.IsDir() {
+			return serveFSFile(fsys, name, hashes)(u)
+		}
+		if !trailingSlash {
+			return redirectToFolder(u)
+		}
+		index := pathpkg.Join(name, "index.html")
+		if info, err := fs.Stat(fsys, index); err == nil && !info.IsDir() {
+			return serveFSFile(fsys, index, hashes)(u)
+		}
+
+		entries, err := readFolder(fsys, name, options)
+		if err != nil {
+			u.Panic(fileError(pathpkg.Clean("/"+urlPath), err))
+			return servePanic(u)
+		}
+		return serveFolderListing(u, entries)
+	}
+}
+
+//fsName turns a slash separated path into a valid name for fs.FS (cleaned and without leading slash, "." for the root)
+func fsName(name string) string {
+	name = strings.TrimPrefix(pathpkg.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+//isDotfile returns true if any segment of the path starts with "."
+func isDotfile(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") && segment != "." {
+			return true
+		}
+	}
+	return false
+}
+
+//readFolder returns the sorted entries of the folder name of fsys (folders first, then by name ignoring case)
+//Entries that could not be served with the options are left out
+func readFolder(fsys fs.FS, name string, options FolderOptions) ([]FolderEntry, error) {
+	dirEntries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]FolderEntry, 0, len(dirEntries))
+	for _, e := range dirEntries {
+		if !options.ShowDotfiles && isDotfile(e.Name()) {
+			continue
+		}
+		//Stat follows symlinks (and rejects the ones pointing outside of a sandboxFS)
+		info, err := fs.Stat(fsys, pathpkg.Join(name, e.Name()))
+		if err != nil {
+			continue
+		}
+		entry := FolderEntry{Name: e.Name(), Dir: info.IsDir(), Modified: info.ModTime().UTC()}
+		if !entry.Dir {
+			entry.Size = info.Size()
+		}
+		entries = append(entries, entry)
+	}
+	sortFolder(entries)
+	return entries, nil
+}
+
+//sandboxFS is the fs.FS of the folder serving WebParts for folders on disk, it only opens files inside root (see resolveIn)
+type sandboxFS struct {
+	root    string
+	options FolderOptions
+}
+
+func (s sandboxFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	file, _, err := resolveIn(s.root, name, s.options)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(file)
+}
+
+//resolveIn returns the file with the slash separated path name inside root and its FileInfo
+//name is cleaned so it cannot leave root, dotfiles and symlinks pointing outside of root are rejected unless the options allow them.
+//All errors are 404 StatusErrors that do not tell the reason (see fileError)
+func resolveIn(root, name string, options FolderOptions) (string, os.FileInfo, error) {
+	name = pathpkg.Clean("/" + name)
+	if strings.ContainsRune(name, 0) || (filepath.Separator != '/' && strings.ContainsRune(name, filepath.Separator)) {
+		return "", nil, fileError(name, os.ErrNotExist)
+	}
+	if !options.ShowDotfiles && isDotfile(name) {
+		return "", nil, fileError(name, os.ErrNotExist)
+	}
+	file := filepath.Join(root, filepath.FromSlash(name))
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", nil, fileError(name, err)
+	}
+	if !options.AllowSymlinks {
+		realRoot, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			return "", nil, fileError(name, err)
+		}
+		realFile, err := filepath.EvalSymlinks(file)
+		if err != nil {
+			return "", nil, fileError(name, err)
+		}
+		rel, err := filepath.Rel(realRoot, realFile)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", nil, fileError(name, os.ErrPermission)
+		}
+	}
+	return file, info, nil
+}
+
+//fileError turns errors of the file system into a 404 StatusError that only tells that name was not found
+//The original error is kept for errors.Is/As, e.g. errors.Is(err, os.ErrPermission)
+func fileError(name string, err error) error {
+	return StatusError{http.StatusNotFound, notFoundError{name, err}}
+}
+
+//notFoundError hides the reason why a file could not be served
+type notFoundError struct {
+	name  string
+	cause error
+}
+
+func (e notFoundError) Error() string {
+	return e.name + " not found"
+}
+
+func (e notFoundError) Unwrap() error {
+	return e.cause
+}
+
+//sortFolder sorts folders first, then by name ignoring case
+func sortFolder(entries []FolderEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Dir != entries[j].Dir {
+			return entries[i].Dir
+		}
+		a, b := strings.ToLower(entries[i].Name), strings.ToLower(entries[j].Name)
+		if a == b {
+			return entries[i].Name < entries[j].Name
+		}
+		return a < b
+	})
+}
+
+//redirectToFolder redirects (301) to the request path with trailing slash
+func redirectToFolder(u WebUnit) *WebUnit {
+	//a relative location keeps prefixes removed by StripPrefix
+	location := "./" + pathpkg.Base(requestPath(u)) + "/"
+	if u.Request.URL.RawQuery != "" {
+		location += "?" + u.Request.URL.RawQuery
+	}
+	u.Writer.Header().Set(HeaderKeyLocation, location)
+	u.Writer.WriteHeader(http.StatusMovedPermanently)
+	return &u
+}
+
+//requestPath returns the path the client requested (before any StripPrefix)
+func requestPath(u WebUnit) string {
+	if u.Request.RequestURI != "" {
+		if parsed, err := url.ParseRequestURI(u.Request.RequestURI); err == nil {
+			return parsed.Path
+		}
+	}
+	return u.Request.URL.Path
+}
+
+//serveFolderListing serves the entries as HTML or as JSON if the client prefers it
+func serveFolderListing(u WebUnit, entries []FolderEntry) *WebUnit {
+	AddVary(u.Writer.Header(), HeaderKeyAccept)
+	if u.Request.Header.Get(HeaderKeyAccept) != "" && u.PreferredType(ContentTypeHTML, ContentTypeJSON) == ContentTypeJSON {
+		return ServeJSON(entries)(u)
+	}
+	return serveBytes(contentTypeHTMLUTF8, func(u WebUnit) ([]byte, error) {
+		p := requestPath(u)
+		listing := folderListing{Path: p, Parent: p != "/"}
+		for _, e := range entries {
+			link := "./" + url.PathEscape(e.Name)
+			size := formatSize(e.Size)
+			if e.Dir {
+				link += "/"
+				size = ""
+			}
+			listing.Entries = append(listing.Entries, folderListingEntry{e, link, size, e.Modified.Format("2006-01-02 15:04:05")})
+		}
+		var b bytes.Buffer
+		if err := folderTemplate.Execute(&b, listing); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	})(u)
+}
+
+//folderListing is the data of folderTemplate
+type folderListing struct {
+	Path    string
+	Parent  bool
+	Entries []folderListingEntry
+}
+
+//folderListingEntry is a FolderEntry formatted for folderTemplate
+type folderListingEntry struct {
+	FolderEntry
+	Link, Size, Time string
+}
+
+var folderTemplate = template.Must(template.New("folder").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Index of {{.Path}}</title>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<hr>
+<table>
+<tr><th align="left">Name</th><th align="right">Size</th><th align="left">Modified</th></tr>
+{{- if .Parent}}
+<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{- end}}
+{{- range .Entries}}
+<tr><td><a href="{{.Link}}">{{.Name}}{{if .Dir}}/{{end}}</a></td><td align="right">{{.Size}}</td><td>{{.Time}}</td></tr>
+{{- end}}
+</table>
+<hr>
+</body>
+</html>
+`))
+
+//formatSize formats a size in bytes human readable like 1.5 KB
+func formatSize(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, unit := float64(size), 0
+	for value >= 1024 && unit < 4 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", value, []string{"B", "KB", "MB", "GB", "TB"}[unit])
+}
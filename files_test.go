@@ -0,0 +1,159 @@
+package grest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestServeFolder(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.MkdirAll(filepath.Join(dir, "site"), 0755)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("bbb"), 0644)
+	os.WriteFile(filepath.Join(dir, "A <a>.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "site", "index.html"), []byte("<h1>site</h1>"), 0644)
+	routes := StripPrefix("/static").ServeFolder(dir)
+
+	serve := func(target string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		router{routes}.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("/static/", nil)
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get(HeaderKeyContentType), ContentTypeHTML) {
+		t.Fatalf("listing should be served as HTML but was %d %s", w.Code, w.Header().Get(HeaderKeyContentType))
+	}
+	site, sub, a, b := strings.Index(body, `href="./site/"`), strings.Index(body, `href="./sub/"`), strings.Index(body, `href="./A%20%3Ca%3E.txt"`), strings.Index(body, `href="./b.txt"`)
+	if site < 0 || sub < site || a < sub || b < a || !strings.Contains(body, "A &lt;a&gt;.txt") || !strings.Contains(body, "Index of /static/") {
+		t.Errorf("listing should contain sorted and escaped links but was %s", body)
+	}
+
+	w = serve("/static/", http.Header{HeaderKeyAccept: {ContentTypeJSON}})
+	var entries []FolderEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil || len(entries) != 4 || entries[3].Name != "b.txt" || entries[3].Size != 3 || !entries[0].Dir {
+		t.Errorf("JSON listing should contain all entries but was %s", w.Body.String())
+	}
+
+	if w = serve("/static/b.txt", nil); w.Code != http.StatusOK || w.Body.String() != "bbb" {
+		t.Errorf("file should be served but was %d %s", w.Code, w.Body.String())
+	}
+	if w = serve("/static/site", nil); w.Code != http.StatusMovedPermanently || w.Header().Get(HeaderKeyLocation) != "./site/" {
+		t.Errorf("folder without trailing slash should be redirected but was %d %s", w.Code, w.Header().Get(HeaderKeyLocation))
+	}
+	if w = serve("/static", nil); w.Code != http.StatusMovedPermanently || w.Header().Get(HeaderKeyLocation) != "./static/" {
+		t.Errorf("root folder without trailing slash should be redirected but was %d %s", w.Code, w.Header().Get(HeaderKeyLocation))
+	}
+	if w = serve("/static/site/", nil); w.Body.String() != "<h1>site</h1>" {
+		t.Errorf("index.html should be served but was %s", w.Body.String())
+	}
+	if w = serve("/static/missing.txt", nil); w.Code != http.StatusNotFound {
+		t.Errorf("missing file should be 404 but was %d", w.Code)
+	}
+	if w = serve("/static/../../etc/passwd", nil); w.Code != http.StatusNotFound {
+		t.Errorf("path outside of the folder should be 404 but was %d", w.Code)
+	}
+}
+
+func TestServeFolderSandbox(t *testing.T) {
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "public.txt"), []byte("public"), 0644)
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("PASSWORD=1"), 0644)
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(dir, "escape.txt")); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+	os.Symlink(filepath.Join(dir, "public.txt"), filepath.Join(dir, "link.txt"))
+
+	cases := []struct {
+		part   WebPart
+		target string
+		status int
+	}{
+		{ServeFolder(dir), "/public.txt", http.StatusOK},
+		{ServeFolder(dir), "/link.txt", http.StatusOK},
+		{ServeFolder(dir), "/escape.txt", http.StatusNotFound},
+		{ServeFolderWith(dir, FolderOptions{AllowSymlinks: true}), "/escape.txt", http.StatusOK},
+		{ServeFolder(dir), "/.env", http.StatusNotFound},
+		{ServeFolderWith(dir, FolderOptions{ShowDotfiles: true}), "/.env", http.StatusOK},
+		{ServeFileIn(dir, "../"+filepath.Base(outside)+"/secret.txt", FolderOptions{}), "/", http.StatusNotFound},
+		{ServeFileIn(dir, "public.txt", FolderOptions{}), "/", http.StatusOK},
+		{ServeFile(filepath.Join(dir, "missing.txt")), "/", http.StatusNotFound},
+	}
+	for i, c := range cases {
+		u, w := getTestRecorder(http.MethodGet, c.target, nil)
+		c.part(u)
+		if w.Code != c.status {
+			t.Errorf("case %d should have status %d but was %d %s", i, c.status, w.Code, w.Body.String())
+		}
+		if c.status == http.StatusNotFound && strings.Contains(w.Body.String(), dir) {
+			t.Errorf("case %d should not leak the path: %s", i, w.Body.String())
+		}
+	}
+
+	u, w := getTestRecorder(http.MethodGet, "/", http.Header{HeaderKeyAccept: {ContentTypeJSON}})
+	ServeFolder(dir)(u)
+	var entries []FolderEntry
+	json.Unmarshal(w.Body.Bytes(), &entries)
+	if len(entries) != 2 || entries[0].Name != "link.txt" || entries[1].Name != "public.txt" {
+		t.Errorf("listing should hide dotfiles and escaping symlinks but was %s", w.Body.String())
+	}
+}
+
+func TestServeFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"public/app.js":          {Data: []byte(strings.Repeat("console.log(1);", 10))},
+		"public/docs/readme.txt": {Data: []byte("readme")},
+		"public/.hidden":         {Data: []byte("hidden")},
+		"private.txt":            {Data: []byte("private")},
+	}
+	routes := ETags(false).ServeFS(fsys, "public")
+	serve := func(target string, header http.Header) *httptest.ResponseRecorder {
+		u, w := getTestRecorder(http.MethodGet, target, header)
+		routes(u)
+		return w
+	}
+
+	w := serve("/app.js", nil)
+	etag := w.Header().Get(HeaderKeyETag)
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get(HeaderKeyContentType), "javascript") || etag == "" || w.Header().Get(HeaderKeyAcceptRanges) != "bytes" {
+		t.Errorf("file should be served with Content-Type, ETag and Accept-Ranges but was %d %v", w.Code, w.Header())
+	}
+	if w = serve("/app.js", http.Header{HeaderKeyIfNoneMatch: {etag}}); w.Code != http.StatusNotModified {
+		t.Errorf("unchanged file should result in 304 but was %d", w.Code)
+	}
+	//files without modification time are hashed only once
+	fsys["public/app.js"].Data = []byte(strings.Repeat("console.log(2);", 10))
+	if w = serve("/app.js", nil); w.Header().Get(HeaderKeyETag) != etag {
+		t.Errorf("ETag of a file without modification time should be cached but was %q", w.Header().Get(HeaderKeyETag))
+	}
+	if w = serve("/app.js", http.Header{HeaderKeyRange: {"bytes=0-6"}}); w.Code != http.StatusPartialContent || w.Body.String() != "console" {
+		t.Errorf("range should be served but was %d %q", w.Code, w.Body.String())
+	}
+	if w = serve("/docs/", nil); !strings.Contains(w.Body.String(), `href="./readme.txt"`) {
+		t.Errorf("folder should be listed but was %s", w.Body.String())
+	}
+	for _, target := range []string{"/.hidden", "/../private.txt", "/missing"} {
+		if w = serve(target, nil); w.Code != http.StatusNotFound {
+			t.Errorf("%s should be 404 but was %d", target, w.Code)
+		}
+	}
+
+	u, rec := getTestRecorder(http.MethodGet, "/", nil)
+	ServeFSFile(fsys, "private.txt")(u)
+	if rec.Body.String() != "private" {
+		t.Errorf("ServeFSFile should serve the file but was %q", rec.Body.String())
+	}
+}
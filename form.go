@@ -0,0 +1,341 @@
+package grest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+//formFilesKey to save the uploaded files of a multipart/form-data body in the context
+const formFilesKey contextKey = "formFiles"
+
+//FormOptions configures how multipart/form-data bodies are read
+type FormOptions struct {
+	//MaxFileSize is the maximum size of a single uploaded file in bytes (0 = no limit besides MaxTotalSize)
+	MaxFileSize int64
+	//MaxTotalSize is the maximum size of the whole body in bytes (0 = the body limit, see MaxBodySize)
+	MaxTotalSize int64
+	//AllowedTypes are the accepted MIME types of uploaded files like "image/png" or "image/*" (empty = all types)
+	AllowedTypes []string
+	//SpillSize is the size in bytes from which on uploaded files are written into a temporary file instead of being kept in memory (0 = never)
+	//Temporary files are removed when the request is finished, so spilling needs a WebUnit of the router or NewWebUnit (see WebUnit.Defer)
+	SpillSize int64
+	//TempDir is the directory for temporary files (empty = os.TempDir())
+	TempDir string
+}
+
+//FormFile is a file uploaded with a multipart/form-data body
+type FormFile struct {
+	//Field is the name of the form field
+	Field string
+	//Filename is the name of the file on the client side
+	Filename string
+	//ContentType is the MIME type of the file (as declared by the client or detected from the content if not declared)
+	ContentType string
+	Header      textproto.MIMEHeader
+	//Size of the file in bytes (only known after the file was read completely)
+	Size int64
+
+	data []byte
+	path string
+}
+
+//Open returns the content of an uploaded file that was read by Form(...)
+func (f *FormFile) Open() (io.ReadCloser, error) {
+	if f.path != "" {
+		return os.Open(f.path)
+	}
+	return ioutil.NopCloser(bytes.NewReader(f.data)), nil
+}
+
+//Form parses "application/x-www-form-urlencoded" and "multipart/form-data" bodies into a Data object that can be read with BodyData()
+//Fields with a single value are saved as string, fields with multiple values as []string.
+//Uploaded files are kept in memory and can be read with FormFiles()
+//Bodies that cannot be parsed result in a panic with status 400 (415 for other Content-Types, 413 if the body is larger than the body limit)
+func Form() WebPart {
+	return FormWith(FormOptions{})
+}
+
+//Form parses "application/x-www-form-urlencoded" and "multipart/form-data" bodies into a Data object that can be read with BodyData()
+//Fields with a single value are saved as string, fields with multiple values as []string.
+//Uploaded files are kept in memory and can be read with FormFiles()
+//Bodies that cannot be parsed result in a panic with status 400 (415 for other Content-Types, 413 if the body is larger than the body limit)
+func (w WebPart) Form() WebPart {
+	return Compose(w, Form())
+}
+
+//FormWith parses "application/x-www-form-urlencoded" and "multipart/form-data" bodies into a Data object that can be read with BodyData()
+//Uploaded files are checked and stored according to the options and can be read with FormFiles()
+//Files that are too large result in a panic with status 413, files with a type that is not allowed with 415
+func FormWith(options FormOptions) WebPart {
+	return func(u WebUnit) *WebUnit {
+		d, err := parseForm(&u, options)
+		if err != nil {
+			u.Panic(err)
+			return &u
+		}
+		u.Context = context.WithValue(u.Context, bodyKey, d)
+		return &u
+	}
+}
+
+//FormWith parses "application/x-www-form-urlencoded" and "multipart/form-data" bodies into a Data object that can be read with BodyData()
+//Uploaded files are checked and stored according to the options and can be read with FormFiles()
+//Files that are too large result in a panic with status 413, files with a type that is not allowed with 415
+func (w WebPart) FormWith(options FormOptions) WebPart {
+	return Compose(w, FormWith(options))
+}
+
+//StreamForm reads a "multipart/form-data" body part by part without buffering uploaded files.
+//onFile is called for every file with a reader of its content which respects the limits of options (its read errors are StatusErrors with 400 or 413).
+//The form fields read so far are passed with the WebUnit (BodyData()), all fields are available to the following WebParts.
+//If onFile returns an error the WebUnit panics with it
+func StreamForm(options FormOptions, onFile func(u WebUnit, file *FormFile, content io.Reader) error) WebPart {
+	return func(u WebUnit) *WebUnit {
+		d := Data{}
+		err := readMultipart(u, options, d, func(file *FormFile, content io.Reader) error {
+			fu := u
+			fu.Context = context.WithValue(u.Context, bodyKey, d.Clone())
+			return onFile(fu, file, content)
+		})
+		if err != nil {
+			u.Panic(err)
+			return &u
+		}
+		u.Context = context.WithValue(u.Context, bodyKey, d)
+		return &u
+	}
+}
+
+//StreamForm reads a "multipart/form-data" body part by part without buffering uploaded files.
+//onFile is called for every file with a reader of its content which respects the limits of options (its read errors are StatusErrors with 400 or 413).
+//The form fields read so far are passed with the WebUnit (BodyData()), all fields are available to the following WebParts.
+//If onFile returns an error the WebUnit panics with it
+func (w WebPart) StreamForm(options FormOptions, onFile func(u WebUnit, file *FormFile, content io.Reader) error) WebPart {
+	return Compose(w, StreamForm(options, onFile))
+}
+
+//FormFiles returns the files uploaded for the given form field (read by Form() or FormWith(...))
+func (u WebUnit) FormFiles(field string) []*FormFile {
+	files, _ := u.Context.Value(formFilesKey).(map[string][]*FormFile)
+	return files[field]
+}
+
+//FormFile returns the first file uploaded for the given form field or nil
+func (u WebUnit) FormFile(field string) *FormFile {
+	files := u.FormFiles(field)
+	if len(files) == 0 {
+		return nil
+	}
+	return files[0]
+}
+
+//=== Helpers =====================================================================================
+
+//isForm returns true if the media type is one of the form types
+func isForm(mediaType string) bool {
+	return mediaType == ContentTypeForm || mediaType == ContentTypeMultipartForm
+}
+
+//parseForm parses form bodies and stores uploaded files in the context of u
+func parseForm(u *WebUnit, options FormOptions) (Data, error) {
+	switch mediaType(*u) {
+	case ContentTypeForm:
+		data, err := readBody(u)
+		if err != nil {
+			return nil, err
+		}
+		values, err := url.ParseQuery(string(data))
+		if err != nil {
+			return nil, StatusError{http.StatusBadRequest, err}
+		}
+		return valuesToData(values), nil
+
+	case ContentTypeMultipartForm:
+		d := Data{}
+		files := map[string][]*FormFile{}
+		err := readMultipart(*u, options, d, func(file *FormFile, content io.Reader) error {
+			if err := storeFile(u, options, file, content); err != nil {
+				return err
+			}
+			files[file.Field] = append(files[file.Field], file)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		u.Context = context.WithValue(u.Context, formFilesKey, files)
+		return d, nil
+
+	default:
+		return nil, NewStatusError(http.StatusUnsupportedMediaType, "unsupported Content-Type: %s (expected %s or %s)", mediaType(*u), ContentTypeForm, ContentTypeMultipartForm)
+	}
+}
+
+//valuesToData converts url.Values into Data (single values as string, multiple values as []string)
+func valuesToData(values map[string][]string) Data {
+	d := Data{}
+	for k, v := range values {
+		if len(v) == 1 {
+			d[k] = v[0]
+		} else {
+			d[k] = v
+		}
+	}
+	return d
+}
+
+//readMultipart reads all parts of a multipart/form-data body, putting the fields into d and passing files to onFile
+func readMultipart(u WebUnit, options FormOptions, d Data, onFile func(*FormFile, io.Reader) error) error {
+	mt, params, err := mime.ParseMediaType(u.Request.Header.Get(HeaderKeyContentType))
+	if err != nil || mt != ContentTypeMultipartForm {
+		return NewStatusError(http.StatusUnsupportedMediaType, "unsupported Content-Type: %s (expected %s)", mediaType(u), ContentTypeMultipartForm)
+	}
+	if params["boundary"] == "" || u.Request.Body == nil {
+		return NewStatusError(http.StatusBadRequest, "multipart body without boundary")
+	}
+	total := options.MaxTotalSize
+	if total <= 0 {
+		total = bodyLimit(u)
+	}
+	body := &limitReader{u.Request.Body, total, NewStatusError(http.StatusRequestEntityTooLarge, "request body is larger than %d bytes", total)}
+	reader := multipart.NewReader(body, params["boundary"])
+
+	values := map[string][]string{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return bodyError(err)
+		}
+
+		if part.FileName() == "" {
+			value, err := ioutil.ReadAll(part)
+			if err != nil {
+				return bodyError(err)
+			}
+			name := part.FormName()
+			values[name] = append(values[name], string(value))
+			if len(values[name]) == 1 {
+				d[name] = values[name][0]
+			} else {
+				d[name] = values[name]
+			}
+			continue
+		}
+
+		file := &FormFile{Field: part.FormName(), Filename: path.Base(part.FileName()), Header: part.Header}
+		var content io.Reader = part
+		if options.MaxFileSize > 0 {
+			content = &limitReader{part, options.MaxFileSize, NewStatusError(http.StatusRequestEntityTooLarge, "file %s is larger than %d bytes", file.Filename, options.MaxFileSize)}
+		}
+		content, file.ContentType, err = fileType(content, part.Header.Get(HeaderKeyContentType))
+		if err != nil {
+			return bodyError(err)
+		}
+		if !typeAllowed(file.ContentType, options.AllowedTypes) {
+			return NewStatusError(http.StatusUnsupportedMediaType, "file %s has a type that is not allowed: %s", file.Filename, file.ContentType)
+		}
+		counter := &countingReader{r: fileReader{content}}
+		if err := onFile(file, counter); err != nil {
+			return err
+		}
+		//drain the rest of the file in case onFile did not read everything
+		if _, err := io.Copy(ioutil.Discard, counter); err != nil {
+			return bodyError(err)
+		}
+		file.Size = counter.n
+	}
+	return nil
+}
+
+//fileReader reads the content of a file part and wraps its read errors with bodyError,
+//so a truncated upload is answered with 400 while errors of storing the file (e.g. a full disk) remain internal errors
+type fileReader struct {
+	r io.Reader
+}
+
+func (f fileReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = bodyError(err)
+	}
+	return n, err
+}
+
+//fileType returns the declared media type or the detected one if nothing (or application/octet-stream) was declared
+//The returned reader has to be used instead of r because the first bytes may have been read for detection
+func fileType(r io.Reader, declared string) (io.Reader, string, error) {
+	if declared != "" {
+		if mt, _, err := mime.ParseMediaType(declared); err == nil && mt != "application/octet-stream" {
+			return r, mt, nil
+		}
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, "", err
+	}
+	head = head[:n]
+	mt, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return io.MultiReader(bytes.NewReader(head), r), mt, nil
+}
+
+//typeAllowed checks a media type against a list of allowed types (supports wildcards like image/*)
+func typeAllowed(mediaType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == "*/*" || a == mediaType || (strings.HasSuffix(a, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(a, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+//storeFile reads the content of file into memory or into a temporary file if it is larger than options.SpillSize
+func storeFile(u *WebUnit, options FormOptions, file *FormFile, content io.Reader) error {
+	if options.SpillSize <= 0 {
+		data, err := ioutil.ReadAll(content)
+		file.data = data
+		return err
+	}
+
+	var buffer bytes.Buffer
+	n, err := io.CopyN(&buffer, content, options.SpillSize+1)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if n <= options.SpillSize {
+		file.data = buffer.Bytes()
+		return nil
+	}
+
+	f, err := ioutil.TempFile(options.TempDir, "grest-upload-")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	file.path = f.Name()
+	if err := u.Defer(func() { os.Remove(f.Name()) }); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if _, err := io.Copy(f, io.MultiReader(&buffer, content)); err != nil {
+		return err
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package grest
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
 )
 
 const (
@@ -19,6 +21,48 @@ const (
 	HeaderKeyContentEncoding = "Content-Encoding"
 	// HeaderKeySetCookie An HTTP cookie -> Set-Cookie: UserID=JohnDoe; Max-Age=3600; Version=1
 	HeaderKeySetCookie = "Set-Cookie"
+	// HeaderKeyAccept Media type(s) that is/are acceptable for the response. -> Accept: text/html, application/json;q=0.9
+	HeaderKeyAccept = "Accept"
+	// HeaderKeyAcceptEncoding Content encodings that are acceptable for the response. -> Accept-Encoding: gzip, deflate
+	HeaderKeyAcceptEncoding = "Accept-Encoding"
+	// HeaderKeyETag An identifier for a specific version of a resource. -> ETag: "737060cd8c284d8af7ad3082f209582d"
+	HeaderKeyETag = "ETag"
+	// HeaderKeyLastModified The last modified date for the requested object. -> Last-Modified: Tue, 15 Nov 1994 12:45:26 GMT
+	HeaderKeyLastModified = "Last-Modified"
+	// HeaderKeyIfNoneMatch Allows a 304 Not Modified to be returned if content is unchanged. -> If-None-Match: "737060cd8c284d8af7ad3082f209582d"
+	HeaderKeyIfNoneMatch = "If-None-Match"
+	// HeaderKeyIfModifiedSince Allows a 304 Not Modified to be returned if content is unchanged. -> If-Modified-Since: Sat, 29 Oct 1994 19:43:31 GMT
+	HeaderKeyIfModifiedSince = "If-Modified-Since"
+	// HeaderKeyIfMatch Only perform the action if the client supplied entity matches the same entity on the server. -> If-Match: "737060cd8c284d8af7ad3082f209582d"
+	HeaderKeyIfMatch = "If-Match"
+	// HeaderKeyIfUnmodifiedSince Only send the response if the entity has not been modified since a specific time. -> If-Unmodified-Since: Sat, 29 Oct 1994 19:43:31 GMT
+	HeaderKeyIfUnmodifiedSince = "If-Unmodified-Since"
+	// HeaderKeyRange Request only part of an entity. Bytes are numbered from 0. -> Range: bytes=500-999
+	HeaderKeyRange = "Range"
+	// HeaderKeyIfRange If the entity is unchanged, send the missing part(s), otherwise send the entire new entity. -> If-Range: "737060cd8c284d8af7ad3082f209582d"
+	HeaderKeyIfRange = "If-Range"
+	// HeaderKeyContentRange Where in a full body message this partial message belongs. -> Content-Range: bytes 21010-47021/47022
+	HeaderKeyContentRange = "Content-Range"
+	// HeaderKeyAcceptRanges What partial content range types this server supports. -> Accept-Ranges: bytes
+	HeaderKeyAcceptRanges = "Accept-Ranges"
+	// HeaderKeyLocation Used in redirection, or when a new resource has been created. -> Location: http://www.w3.org/pub/WWW/People.html
+	HeaderKeyLocation = "Location"
+	// HeaderKeyXForwardedProto The protocol the client used to connect to a proxy or load balancer. -> X-Forwarded-Proto: https
+	HeaderKeyXForwardedProto = "X-Forwarded-Proto"
+	// HeaderKeyWWWAuthenticate Indicates the authentication scheme that should be used to access the requested entity. -> WWW-Authenticate: Basic
+	HeaderKeyWWWAuthenticate = "WWW-Authenticate"
+	// HeaderKeyRetryAfter If an entity is temporarily unavailable, this instructs the client to try again later. -> Retry-After: 120
+	HeaderKeyRetryAfter = "Retry-After"
+	// HeaderKeyAllow Valid methods for a specified resource. To be used for a 405 Method not allowed -> Allow: GET, HEAD
+	HeaderKeyAllow = "Allow"
+	// HeaderKeyCacheControl Tells all caching mechanisms from server to client whether they may cache this object. -> Cache-Control: max-age=3600
+	HeaderKeyCacheControl = "Cache-Control"
+	// HeaderKeyAge The age the object has been in a proxy cache in seconds. -> Age: 12
+	HeaderKeyAge = "Age"
+	// HeaderKeyVary Tells caches which request headers were used to select the response. -> Vary: Accept, Accept-Encoding
+	HeaderKeyVary = "Vary"
+	// HeaderKeyTrace The evaluation path of the routes when tracing is enabled (see Debug). -> X-Grest-Trace: routes/Choose[0] = nil 3us; routes/Choose[1] = running
+	HeaderKeyTrace = "X-Grest-Trace"
 )
 
 const (
@@ -33,6 +77,15 @@ const (
 
 	// ContentTypeHTML "text/html"
 	ContentTypeHTML = "text/html"
+
+	// ContentTypeCSV "text/csv"
+	ContentTypeCSV = "text/csv"
+
+	// ContentTypeForm "application/x-www-form-urlencoded"
+	ContentTypeForm = "application/x-www-form-urlencoded"
+
+	// ContentTypeMultipartForm "multipart/form-data"
+	ContentTypeMultipartForm = "multipart/form-data"
 )
 
 // ContentType sets the "Content-Type" header
@@ -82,3 +135,22 @@ func (w WebPart) SetHeaders(headers map[string]string) WebPart {
 func (w WebPart) SetHeader(key, value string) WebPart {
 	return Compose(w, SetHeader(key, value))
 }
+
+// AddVary adds keys to the "Vary" header, keeping the values that are already there (duplicates are skipped)
+func AddVary(header http.Header, keys ...string) {
+	existing := map[string]bool{}
+	for _, v := range header.Values(HeaderKeyVary) {
+		for _, k := range strings.Split(v, ",") {
+			existing[strings.ToLower(strings.TrimSpace(k))] = true
+		}
+	}
+	if existing["*"] {
+		return
+	}
+	for _, k := range keys {
+		if !existing[strings.ToLower(k)] {
+			existing[strings.ToLower(k)] = true
+			header.Add(HeaderKeyVary, k)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package grest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+)
+
+//maxRefDepth limits how deep $ref may be followed to stop recursive schemas
+const maxRefDepth = 64
+
+//JSONSchema validates values against a JSON Schema document (a subset of draft 2020-12).
+//Supported keywords: type, enum, const, properties, required, additionalProperties, items, prefixItems,
+//minItems, maxItems, minLength, maxLength, pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum
+//and $ref to locations within the same document (like #/$defs/address)
+type JSONSchema struct {
+	root interface{}
+
+	mu       sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+//NewJSONSchema parses a JSON Schema document
+func NewJSONSchema(document []byte) (*JSONSchema, error) {
+	var root interface{}
+	if err := decodeJSON(document, &root); err != nil {
+		return nil, fmt.Errorf("invalid JSON Schema: %v", err)
+	}
+	switch root.(type) {
+	case map[string]interface{}, bool:
+		return &JSONSchema{root: root, patterns: map[string]*regexp.Regexp{}}, nil
+	default:
+		return nil, fmt.Errorf("invalid JSON Schema: must be an object or a boolean")
+	}
+}
+
+//MustJSONSchema parses a JSON Schema document and panics if that fails (for schemas known at compile time)
+func MustJSONSchema(document []byte) *JSONSchema {
+	s, err := NewJSONSchema(document)
+	try(err)
+	return s
+}
+
+//Validate checks value (Data, a struct or any value decoded from JSON) against the schema
+//Returns the violations with the JSON pointer to the invalid value as Field (nil if value is valid)
+func (s *JSONSchema) Validate(value interface{}) FieldErrors {
+	switch value.(type) {
+	case nil, map[string]interface{}, []interface{}, string, bool, json.Number:
+	default:
+		//Data and structs may contain values like []string or time.Time that are converted to their JSON representation
+		d, err := toJSONValue(value)
+		if err != nil {
+			return FieldErrors{{Field: "", Message: err.Error()}}
+		}
+		value = d
+	}
+	var errs FieldErrors
+	s.validate(s.root, value, "", 0, &errs)
+	return errs
+}
+
+//ValidateJSONSchema checks the parsed request body (see Body() and JSONBody[T]()) against a JSON Schema
+//If it is invalid the WebUnit panics with status 422 (Unprocessable Entity) and FieldErrors named by JSON pointers
+func ValidateJSONSchema(schema *JSONSchema) WebPart {
+	return func(u WebUnit) *WebUnit {
+		if errs := schema.Validate(u.GetBody()); len(errs) > 0 {
+			for i := range errs {
+				errs[i].Source = "body"
+			}
+			u.Panic(StatusError{http.StatusUnprocessableEntity, errs})
+		}
+		return &u
+	}
+}
+
+//ValidateJSONSchema checks the parsed request body (see Body() and JSONBody[T]()) against a JSON Schema
+//If it is invalid the WebUnit panics with status 422 (Unprocessable Entity) and FieldErrors named by JSON pointers
+func (w WebPart) ValidateJSONSchema(schema *JSONSchema) WebPart {
+	return Compose(w, ValidateJSONSchema(schema))
+}
+
+//=== Helpers =====================================================================================
+
+func (s *JSONSchema) validate(schema interface{}, value interface{}, pointer string, depth int, errs *FieldErrors) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var sc Data
+	switch typed := schema.(type) {
+	case bool:
+		if !typed {
+			fail("is not allowed")
+		}
+		return
+	case map[string]interface{}:
+		sc = typed
+	default:
+		return
+	}
+
+	if ref, ok := sc["$ref"].(string); ok {
+		if depth >= maxRefDepth {
+			fail("$ref %s is nested too deep", ref)
+			return
+		}
+		target, err := s.resolve(ref)
+		if err != nil {
+			fail("%v", err)
+			return
+		}
+		s.validate(target, value, pointer, depth+1, errs)
+	}
+
+	if t, ok := sc["type"]; ok {
+		types, _ := array(t)
+		if types == nil {
+			types = []interface{}{t}
+		}
+		matches := false
+		for _, t := range types {
+			if hasJSONType(value, fmt.Sprint(t)) {
+				matches = true
+				break
+			}
+		}
+		if !matches {
+			fail("must be of type %v", t)
+			return
+		}
+	}
+
+	if enum, ok := sc["enum"]; ok {
+		values, _ := array(enum)
+		matches := false
+		for _, v := range values {
+			if jsonEqual(v, value) {
+				matches = true
+				break
+			}
+		}
+		if !matches {
+			fail("must be one of %v", enum)
+		}
+	}
+	if c, ok := sc["const"]; ok && !jsonEqual(c, value) {
+		fail("must be %v", c)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		s.validateObject(sc, v, pointer, depth, errs)
+	case []interface{}:
+		s.validateArray(sc, v, pointer, depth, errs)
+	case string:
+		length := utf8.RuneCountInString(v)
+		if min := sc.Int64("minLength"); min != nil && int64(length) < *min {
+			fail("must have a length of at least %d", *min)
+		}
+		if max := sc.Int64("maxLength"); max != nil && int64(length) > *max {
+			fail("must have a length of at most %d", *max)
+		}
+		if pattern, ok := sc["pattern"].(string); ok {
+			re, err := s.pattern(pattern)
+			if err != nil {
+				fail("invalid pattern %s in schema", pattern)
+			} else if !re.MatchString(v) {
+				fail("must match %s", pattern)
+			}
+		}
+	default:
+		if n, ok := jsonNumber(value); ok {
+			if min := sc.Decimal("minimum"); min != nil && n.LessThan(*min) {
+				fail("must be at least %s", min)
+			}
+			if max := sc.Decimal("maximum"); max != nil && n.GreaterThan(*max) {
+				fail("must be at most %s", max)
+			}
+			if min := sc.Decimal("exclusiveMinimum"); min != nil && n.LessThanOrEqual(*min) {
+				fail("must be greater than %s", min)
+			}
+			if max := sc.Decimal("exclusiveMaximum"); max != nil && n.GreaterThanOrEqual(*max) {
+				fail("must be less than %s", max)
+			}
+		}
+	}
+}
+
+func (s *JSONSchema) validateObject(sc Data, object map[string]interface{}, pointer string, depth int, errs *FieldErrors) {
+	if required, ok := array(sc["required"]); ok {
+		for _, r := range required {
+			if _, ok := object[fmt.Sprint(r)]; !ok {
+				*errs = append(*errs, FieldError{Field: pointer + "/" + escapePointer(fmt.Sprint(r)), Message: "is required"})
+			}
+		}
+	}
+
+	properties := sc.Data("properties")
+	keys := make([]string, 0, len(object))
+	for k := range object {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		child := pointer + "/" + escapePointer(k)
+		if properties != nil && properties.Has(k) {
+			s.validate((*properties)[k], object[k], child, depth, errs)
+		} else if additional, ok := sc["additionalProperties"]; ok {
+			s.validate(additional, object[k], child, depth, errs)
+		}
+	}
+}
+
+func (s *JSONSchema) validateArray(sc Data, elements []interface{}, pointer string, depth int, errs *FieldErrors) {
+	if min := sc.Int64("minItems"); min != nil && int64(len(elements)) < *min {
+		*errs = append(*errs, FieldError{Field: pointer, Message: fmt.Sprintf("must have at least %d items", *min)})
+	}
+	if max := sc.Int64("maxItems"); max != nil && int64(len(elements)) > *max {
+		*errs = append(*errs, FieldError{Field: pointer, Message: fmt.Sprintf("must have at most %d items", *max)})
+	}
+	prefix, _ := array(sc["prefixItems"])
+	items, hasItems := sc["items"]
+	for i, e := range elements {
+		child := pointer + "/" + strconv.Itoa(i)
+		if i < len(prefix) {
+			s.validate(prefix[i], e, child, depth, errs)
+		} else if hasItems {
+			s.validate(items, e, child, depth, errs)
+		}
+	}
+}
+
+//resolve returns the part of the document a $ref like "#/$defs/address" points to
+func (s *JSONSchema) resolve(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("$ref %s is not supported (only references within the document)", ref)
+	}
+	current := s.root
+	pointer := strings.TrimPrefix(ref, "#")
+	if pointer == "" {
+		return current, nil
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		switch c := current.(type) {
+		case map[string]interface{}:
+			next, ok := c[token]
+			if !ok {
+				return nil, fmt.Errorf("$ref %s cannot be resolved", ref)
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, fmt.Errorf("$ref %s cannot be resolved", ref)
+			}
+			current = c[i]
+		default:
+			return nil, fmt.Errorf("$ref %s cannot be resolved", ref)
+		}
+	}
+	return current, nil
+}
+
+//pattern returns the compiled regular expression (cached)
+func (s *JSONSchema) pattern(expr string) (*regexp.Regexp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if re, ok := s.patterns[expr]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	s.patterns[expr] = re
+	return re, nil
+}
+
+//escapePointer escapes a key for the use in a JSON pointer
+func escapePointer(key string) string {
+	return strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+}
+
+//hasJSONType checks a value decoded from JSON against a JSON Schema type name
+func hasJSONType(value interface{}, t string) bool {
+	switch t {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "object":
+		switch value.(type) {
+		case map[string]interface{}, Data:
+			return true
+		}
+		return false
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "number":
+		_, ok := jsonNumber(value)
+		return ok
+	case "integer":
+		n, ok := jsonNumber(value)
+		return ok && n.Equal(n.Truncate(0))
+	}
+	return false
+}
+
+//jsonNumber returns numbers (json.Number, float64, ints) as decimal
+func jsonNumber(value interface{}) (decimal.Decimal, bool) {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		if _, ok := value.(json.Number); !ok {
+			return decimal.Decimal{}, false
+		}
+	}
+	n, err := decimal.NewFromString(fmt.Sprint(value))
+	return n, err == nil
+}
+
+//jsonEqual compares two values decoded from JSON (numbers are compared by value)
+func jsonEqual(a, b interface{}) bool {
+	if na, ok := jsonNumber(a); ok {
+		nb, ok := jsonNumber(b)
+		return ok && na.Equal(nb)
+	}
+	aa, aIsArray := a.([]interface{})
+	ba, bIsArray := b.([]interface{})
+	if aIsArray || bIsArray {
+		if !aIsArray || !bIsArray || len(aa) != len(ba) {
+			return false
+		}
+		for i := range aa {
+			if !jsonEqual(aa[i], ba[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	ao, aIsObject := jsonObject(a)
+	bo, bIsObject := jsonObject(b)
+	if aIsObject || bIsObject {
+		if !aIsObject || !bIsObject || len(ao) != len(bo) {
+			return false
+		}
+		for k, v := range ao {
+			if w, ok := bo[k]; !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func jsonObject(v interface{}) (map[string]interface{}, bool) {
+	switch o := v.(type) {
+	case map[string]interface{}:
+		return o, true
+	case Data:
+		return o, true
+	}
+	return nil, false
+}
+
+//toJSONValue converts any value into its JSON representation (maps, slices, strings, json.Number, bools)
+func toJSONValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	if err := decodeJSON(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
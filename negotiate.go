@@ -0,0 +1,219 @@
+package grest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//Encoder turns a value into a representation of the given media type
+type Encoder struct {
+	//ContentType of the representation, e.g.: "application/json" or "text/plain; charset=utf-8"
+	ContentType string
+	Encode      func(v interface{}) ([]byte, error)
+}
+
+//JSONEncoder encodes values as JSON
+func JSONEncoder() Encoder {
+	return Encoder{ContentTypeJSON, json.Marshal}
+}
+
+//XMLEncoder encodes values as XML (Data and Datas are supported, see Data.MarshalXML)
+func XMLEncoder() Encoder {
+	return Encoder{contentTypeXMLUTF8, marshalXML}
+}
+
+//TextEncoder encodes values as plain text with fmt.Sprint
+func TextEncoder() Encoder {
+	return Encoder{contentTypeTextUTF8, func(v interface{}) ([]byte, error) {
+		switch t := v.(type) {
+		case []byte:
+			return t, nil
+		case string:
+			return []byte(t), nil
+		}
+		return []byte(fmt.Sprint(v)), nil
+	}}
+}
+
+//CSVEncoder encodes Datas, []Data, Data (a single row), [][]string and []string (a single row) as CSV
+//Data objects are written with a header line of all keys (sorted)
+func CSVEncoder() Encoder {
+	return Encoder{ContentTypeCSV + "; charset=utf-8", func(v interface{}) ([]byte, error) {
+		records, err := csvRecords(v)
+		if err != nil {
+			return nil, err
+		}
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		if err := w.WriteAll(records); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}}
+}
+
+//Negotiate serves v with the encoder that fits the Accept header of the request best (respecting q-values)
+//If multiple encoders are equally acceptable the first one wins, without Accept header the first encoder is used.
+//The Content-Type header is set to the one of the encoder, if no encoder is acceptable the WebUnit panics with 406 (Not Acceptable)
+func Negotiate(v interface{}, encoders ...Encoder) WebPart {
+	return func(u WebUnit) *WebUnit {
+		AddVary(u.Writer.Header(), HeaderKeyAccept)
+		return ServeReadCloser(func(u WebUnit) (io.ReadCloser, error) {
+			offers := make([]string, len(encoders))
+			for i, e := range encoders {
+				offers[i] = e.ContentType
+			}
+			i := negotiate(u.Request.Header.Get(HeaderKeyAccept), offers)
+			if i < 0 {
+				return nil, NewStatusError(http.StatusNotAcceptable, "none of the available types is acceptable: %s", strings.Join(offers, ", "))
+			}
+			data, err := encoders[i].Encode(v)
+			if err != nil {
+				return nil, err
+			}
+			u.Writer.Header().Set(HeaderKeyContentType, encoders[i].ContentType)
+			return MakeClosable(bytes.NewReader(data), nil), nil
+		})(u)
+	}
+}
+
+//Negotiate serves v with the encoder that fits the Accept header of the request best (respecting q-values)
+//If multiple encoders are equally acceptable the first one wins, without Accept header the first encoder is used.
+//The Content-Type header is set to the one of the encoder, if no encoder is acceptable the WebUnit panics with 406 (Not Acceptable)
+func (w WebPart) Negotiate(v interface{}, encoders ...Encoder) WebPart {
+	return Compose(w, Negotiate(v, encoders...))
+}
+
+//PreferredType returns the offered media type that fits the Accept header of the request best or "" if none is acceptable
+//Without Accept header the first offer is returned
+func (u WebUnit) PreferredType(offers ...string) string {
+	i := negotiate(u.Request.Header.Get(HeaderKeyAccept), offers)
+	if i < 0 {
+		return ""
+	}
+	return offers[i]
+}
+
+//=== Helpers =====================================================================================
+
+//mediaRange is a single entry of an Accept header
+type mediaRange struct {
+	mainType, subType string
+	q                 float64
+}
+
+//parseAccept parses an Accept header into its media ranges
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		mt, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			if mt = strings.ToLower(strings.TrimSpace(strings.Split(part, ";")[0])); mt == "*" {
+				mt = "*/*"
+			}
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(qs, 64); err == nil {
+				q = parsed
+			}
+		}
+		types := strings.SplitN(mt, "/", 2)
+		if len(types) != 2 {
+			continue
+		}
+		ranges = append(ranges, mediaRange{types[0], types[1], q})
+	}
+	return ranges
+}
+
+//negotiate returns the index of the offer with the highest q-value in accept (the most specific media range decides) or -1 if no offer is acceptable
+func negotiate(accept string, offers []string) int {
+	if len(offers) == 0 {
+		return -1
+	}
+	if strings.TrimSpace(accept) == "" {
+		return 0
+	}
+	ranges := parseAccept(accept)
+	best, bestQ := -1, 0.0
+	for i, offer := range offers {
+		mt, _, err := mime.ParseMediaType(offer)
+		if err != nil {
+			continue
+		}
+		types := strings.SplitN(mt, "/", 2)
+		if len(types) != 2 {
+			continue
+		}
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := -1
+			switch {
+			case r.mainType == types[0] && r.subType == types[1]:
+				s = 2
+			case r.mainType == types[0] && r.subType == "*":
+				s = 1
+			case r.mainType == "*" && r.subType == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	return best
+}
+
+//csvRecords converts supported values into CSV records
+func csvRecords(v interface{}) ([][]string, error) {
+	switch t := v.(type) {
+	case [][]string:
+		return t, nil
+	case []string:
+		return [][]string{t}, nil
+	case Data:
+		return csvRecords(Datas{t})
+	case []Data:
+		return csvRecords(Datas(t))
+	case Datas:
+		keySet := map[string]bool{}
+		for _, d := range t {
+			for k := range d {
+				keySet[k] = true
+			}
+		}
+		keys := make([]string, 0, len(keySet))
+		for k := range keySet {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		records := [][]string{keys}
+		for _, d := range t {
+			record := make([]string, len(keys))
+			for i, k := range keys {
+				if d.Has(k) && d[k] != nil {
+					record[i] = *d.String(k)
+				}
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+	return nil, fmt.Errorf("cannot encode %T as CSV", v)
+}
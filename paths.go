@@ -1,11 +1,15 @@
 package grest
 
 import (
+	"context"
 	"regexp"
 	"strconv"
 	"strings"
 )
 
+//pathParamsKey to save the named parameters matched by ParamPath in the context
+const pathParamsKey contextKey = "pathParams"
+
 // Prefix filters paths that dont start with 'prefix'
 func Prefix(prefix string) WebPart {
 	return func(u WebUnit) *WebUnit {
@@ -36,6 +40,33 @@ func (w WebPart) PrefixDirty(prefix string) WebPart {
 	return Compose(w, PrefixDirty(prefix))
 }
 
+// StripPrefix removes 'prefix' from the request path for the following WebParts (e.g. to serve a folder below a path with ServeFolder)
+// Paths that dont start with the path segments of 'prefix' are filtered, e.g. "/static" matches "/static" and "/static/x" but not "/staticx" (doesn't clean path)
+func StripPrefix(prefix string) WebPart {
+	return func(u WebUnit) *WebUnit {
+		if !hasPathPrefix(u.Request.URL.Path, prefix) {
+			return nil
+		}
+		req := *u.Request
+		reqURL := *req.URL
+		reqURL.Path = strings.TrimPrefix(reqURL.Path, prefix)
+		if hasPathPrefix(reqURL.RawPath, prefix) {
+			reqURL.RawPath = strings.TrimPrefix(reqURL.RawPath, prefix)
+		} else {
+			reqURL.RawPath = ""
+		}
+		req.URL = &reqURL
+		u.Request = &req
+		return &u
+	}
+}
+
+// StripPrefix (composing) removes 'prefix' from the request path for the following WebParts (e.g. to serve a folder below a path with ServeFolder)
+// Paths that dont start with the path segments of 'prefix' are filtered, e.g. "/static" matches "/static" and "/static/x" but not "/staticx" (doesn't clean path)
+func (w WebPart) StripPrefix(prefix string) WebPart {
+	return Compose(w, StripPrefix(prefix))
+}
+
 // Path matches exact path
 func Path(path string) WebPart {
 	return func(u WebUnit) *WebUnit {
@@ -119,6 +150,43 @@ func (w WebPart) TypedPath(pattern string, do func(WebUnit, []interface{}) *WebU
 	return Compose(w, TypedPath(pattern, do))
 }
 
+// ParamPath matches paths against a pattern with named parameters
+// e.g.: /users/{id}/posts/{post} matches http://test.de/users/5/posts/hello
+// The parameter values are put into a Data object that can be read with PathParams() (as strings)
+func ParamPath(pattern string) WebPart {
+	return func(u WebUnit) *WebUnit {
+		pParts := strings.Split(strings.Trim(pattern, "/"), "/")
+		uParts := strings.Split(strings.Trim(u.Request.URL.Path, "/"), "/")
+
+		if len(pParts) != len(uParts) {
+			return nil
+		}
+		params := Data{}
+		for i, p := range pParts {
+			if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+				params[p[1:len(p)-1]] = uParts[i]
+			} else if p != uParts[i] {
+				return nil
+			}
+		}
+		u.Context = context.WithValue(u.Context, pathParamsKey, u.PathParams().Union(params))
+		return &u
+	}
+}
+
+// ParamPath matches paths against a pattern with named parameters
+// e.g.: /users/{id}/posts/{post} matches http://test.de/users/5/posts/hello
+// The parameter values are put into a Data object that can be read with PathParams() (as strings)
+func (w WebPart) ParamPath(pattern string) WebPart {
+	return Compose(w, ParamPath(pattern))
+}
+
+// PathParams returns the named parameters matched by ParamPath or nil if there are none
+func (u WebUnit) PathParams() Data {
+	params, _ := u.Context.Value(pathParamsKey).(Data)
+	return params
+}
+
 // RegexPath matches path by regular expression
 // e.g.: ^/[a-z]+[0-9]+$ matches http://website.de/test1
 // if no match
@@ -170,3 +238,11 @@ func Clean(path string) string {
 	}
 	return path
 }
+
+// hasPathPrefix returns true if path starts with prefix at a segment boundary
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
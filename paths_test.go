@@ -195,3 +195,53 @@ func TestRegexPath(t *testing.T) {
 		}
 	}
 }
+
+func TestParamPath(t *testing.T) {
+	cases := []struct {
+		pattern string
+		url     string
+		params  Data
+	}{
+		{"/users/{id}", "/users/5", Data{"id": "5"}},
+		{"/users/{id}/posts/{post}", "/users/5/posts/hello/", Data{"id": "5", "post": "hello"}},
+		{"/users/{id}", "/users/5/posts", nil},
+		{"/users/{id}", "/groups/5", nil},
+	}
+
+	for _, c := range cases {
+		result := ParamPath(c.pattern)(getTestContext("http://text.de" + c.url))
+		if (result == nil) != (c.params == nil) {
+			t.Errorf(`ParamPath("%s") on URL=%s should be %v`, c.pattern, c.url, c.params != nil)
+			continue
+		}
+		if result != nil && fmt.Sprint(result.PathParams()) != fmt.Sprint(c.params) {
+			t.Errorf(`ParamPath("%s") on URL=%s should have params %v but has %v`, c.pattern, c.url, c.params, result.PathParams())
+		}
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	cases := []struct {
+		prefix string
+		url    string
+		path   string
+		pass   bool
+	}{
+		{"/static", "/static/app.js", "/app.js", true},
+		{"/static", "/static", "", true},
+		{"/static/", "/static/app.js", "app.js", true},
+		{"/static", "/staticfoo", "", false},
+		{"/static", "/other/static", "", false},
+	}
+
+	for _, c := range cases {
+		result := StripPrefix(c.prefix)(getTestContext("http://text.de" + c.url))
+		if (result != nil) != c.pass {
+			t.Errorf(`StripPrefix("%s") on URL=%s should be %v`, c.prefix, c.url, c.pass)
+			continue
+		}
+		if result != nil && result.Request.URL.Path != c.path {
+			t.Errorf(`StripPrefix("%s") on URL=%s should have path %q but has %q`, c.prefix, c.url, c.path, result.Request.URL.Path)
+		}
+	}
+}
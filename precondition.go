@@ -0,0 +1,90 @@
+package grest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+//Version describes the current version of a resource for the precondition checks of IfMatch (the zero Version means the resource does not exist)
+type Version struct {
+	//ETag of the current version, e.g. `"v42"` or `W/"v42"` (unquoted values are quoted)
+	ETag string
+	//LastModified is the time of the last change (zero = unknown)
+	LastModified time.Time
+}
+
+//IfMatch checks the preconditions of the request against the current version of the resource returned by getVersion (to prevent lost updates with PUT, PATCH and DELETE):
+//	If-Match: the ETag has to match one of the given ETags (strong comparison, "*" matches any existing resource)
+//	If-Unmodified-Since: only evaluated without If-Match, the resource must not have been modified after the given time
+//	If-None-Match: the ETag must not match any of the given ETags ("*" means the resource must not exist yet, e.g. to prevent overwriting with PUT)
+//If a precondition fails the WebUnit panics with 412 (Precondition Failed), errors of getVersion (e.g. a StatusError with 404) are put into the panic context
+func IfMatch(getVersion func(u WebUnit) (Version, error)) WebPart {
+	return checkPreconditions(false, getVersion)
+}
+
+//IfMatch checks the preconditions of the request against the current version of the resource returned by getVersion (to prevent lost updates with PUT, PATCH and DELETE):
+//	If-Match: the ETag has to match one of the given ETags (strong comparison, "*" matches any existing resource)
+//	If-Unmodified-Since: only evaluated without If-Match, the resource must not have been modified after the given time
+//	If-None-Match: the ETag must not match any of the given ETags ("*" means the resource must not exist yet, e.g. to prevent overwriting with PUT)
+//If a precondition fails the WebUnit panics with 412 (Precondition Failed), errors of getVersion (e.g. a StatusError with 404) are put into the panic context
+func (w WebPart) IfMatch(getVersion func(u WebUnit) (Version, error)) WebPart {
+	return Compose(w, IfMatch(getVersion))
+}
+
+//RequireIfMatch works like IfMatch but demands that the request has an If-Match or If-Unmodified-Since header, otherwise the WebUnit panics with 428 (Precondition Required)
+func RequireIfMatch(getVersion func(u WebUnit) (Version, error)) WebPart {
+	return checkPreconditions(true, getVersion)
+}
+
+//RequireIfMatch works like IfMatch but demands that the request has an If-Match or If-Unmodified-Since header, otherwise the WebUnit panics with 428 (Precondition Required)
+func (w WebPart) RequireIfMatch(getVersion func(u WebUnit) (Version, error)) WebPart {
+	return Compose(w, RequireIfMatch(getVersion))
+}
+
+//=== Helpers =====================================================================================
+
+//checkPreconditions evaluates If-Match, If-Unmodified-Since and If-None-Match in the order defined by RFC 7232
+func checkPreconditions(required bool, getVersion func(u WebUnit) (Version, error)) WebPart {
+	return func(u WebUnit) *WebUnit {
+		ifMatch := u.Request.Header.Get(HeaderKeyIfMatch)
+		//an invalid date is ignored like a missing If-Unmodified-Since (RFC 7232)
+		ifUnmodifiedSince, err := http.ParseTime(u.Request.Header.Get(HeaderKeyIfUnmodifiedSince))
+		hasUnmodifiedSince := err == nil
+		if required && ifMatch == "" && !hasUnmodifiedSince {
+			u.Panic(NewStatusError(http.StatusPreconditionRequired, "precondition required: send If-Match with the current ETag of the resource"))
+			return &u
+		}
+		ifNoneMatch := u.Request.Header.Get(HeaderKeyIfNoneMatch)
+		if ifMatch == "" && !hasUnmodifiedSince && ifNoneMatch == "" {
+			return &u
+		}
+
+		v, err := getVersion(u)
+		if err != nil {
+			u.Panic(err)
+			return &u
+		}
+		etag := v.ETag
+		if etag != "" && !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+			etag = `"` + etag + `"`
+		}
+		exists := etag != "" || !v.LastModified.IsZero()
+
+		failed := ""
+		if ifMatch != "" {
+			if !exists || !(strings.TrimSpace(ifMatch) == "*" || (etag != "" && etagMatches(ifMatch, etag, true))) {
+				failed = HeaderKeyIfMatch
+			}
+		} else if hasUnmodifiedSince && !v.LastModified.IsZero() && v.LastModified.Truncate(time.Second).After(ifUnmodifiedSince) {
+			failed = HeaderKeyIfUnmodifiedSince
+		}
+		if failed == "" && ifNoneMatch != "" && exists && (strings.TrimSpace(ifNoneMatch) == "*" || (etag != "" && etagMatches(ifNoneMatch, etag, false))) {
+			failed = HeaderKeyIfNoneMatch
+		}
+		if failed != "" {
+			u.Panic(NewStatusError(http.StatusPreconditionFailed, "precondition failed: %s does not match the current version of the resource", failed))
+		}
+		return &u
+	}
+}
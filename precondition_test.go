@@ -0,0 +1,40 @@
+package grest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIfMatch(t *testing.T) {
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	current := func(WebUnit) (Version, error) { return Version{ETag: "v2", LastModified: modified}, nil }
+	missing := func(WebUnit) (Version, error) { return Version{}, nil }
+	cases := []struct {
+		header http.Header
+		part   WebPart
+		status int
+	}{
+		{http.Header{}, IfMatch(current), 0},
+		{http.Header{}, RequireIfMatch(current), http.StatusPreconditionRequired},
+		{http.Header{HeaderKeyIfMatch: {`"v2"`}}, RequireIfMatch(current), 0},
+		{http.Header{HeaderKeyIfMatch: {`"v1", "v2"`}}, IfMatch(current), 0},
+		{http.Header{HeaderKeyIfMatch: {`"v1"`}}, IfMatch(current), http.StatusPreconditionFailed},
+		{http.Header{HeaderKeyIfMatch: {`W/"v2"`}}, IfMatch(current), http.StatusPreconditionFailed},
+		{http.Header{HeaderKeyIfMatch: {"*"}}, IfMatch(current), 0},
+		{http.Header{HeaderKeyIfMatch: {"*"}}, IfMatch(missing), http.StatusPreconditionFailed},
+		{http.Header{HeaderKeyIfUnmodifiedSince: {modified.Format(http.TimeFormat)}}, RequireIfMatch(current), 0},
+		{http.Header{HeaderKeyIfUnmodifiedSince: {modified.Add(-time.Hour).Format(http.TimeFormat)}}, IfMatch(current), http.StatusPreconditionFailed},
+		{http.Header{HeaderKeyIfUnmodifiedSince: {"x"}}, RequireIfMatch(current), http.StatusPreconditionRequired},
+		{http.Header{HeaderKeyIfUnmodifiedSince: {"x"}}, IfMatch(current), 0},
+		{http.Header{HeaderKeyIfNoneMatch: {"*"}}, IfMatch(missing), 0},
+		{http.Header{HeaderKeyIfNoneMatch: {"*"}}, IfMatch(current), http.StatusPreconditionFailed},
+		{http.Header{HeaderKeyIfMatch: {`"v2"`}}, IfMatch(func(WebUnit) (Version, error) { return Version{}, NewStatusError(http.StatusNotFound, "gone") }), http.StatusNotFound},
+	}
+	for i, c := range cases {
+		u, _ := getTestRecorder(http.MethodPut, "/", c.header)
+		if result := c.part(u); result.GetPanicStatus() != c.status {
+			t.Errorf("case %d should have status %d but was %v", i, c.status, result.GetPanic())
+		}
+	}
+}
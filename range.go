@@ -0,0 +1,221 @@
+package grest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+//byteRange is a single range of a Range header
+type byteRange struct {
+	start, length int64
+}
+
+//contentRange formats the range for the Content-Range header
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+var (
+	//errInvalidRange is returned by parseRange for malformed Range headers (they are ignored)
+	errInvalidRange = errors.New("invalid range")
+	//errNoOverlap is returned by parseRange if no range is satisfiable (416)
+	errNoOverlap = errors.New("no range overlaps the content")
+)
+
+//parseRange parses a Range header like "bytes=0-99,200-,-50" for content of the given size
+func parseRange(s string, size int64) ([]byteRange, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(s, prefix) {
+		return nil, errInvalidRange
+	}
+	var ranges []byteRange
+	noOverlap := false
+	for _, part := range strings.Split(s[len(prefix):], ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		startStr, endStr, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, errInvalidRange
+		}
+		startStr, endStr = strings.TrimSpace(startStr), strings.TrimSpace(endStr)
+		var r byteRange
+		if startStr == "" {
+			//suffix range: the last n bytes
+			n, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil || n < 0 {
+				return nil, errInvalidRange
+			}
+			if n > size {
+				n = size
+			}
+			if n == 0 {
+				noOverlap = true
+				continue
+			}
+			r = byteRange{size - n, n}
+		} else {
+			start, err := strconv.ParseInt(startStr, 10, 64)
+			if err != nil || start < 0 {
+				return nil, errInvalidRange
+			}
+			if start >= size {
+				noOverlap = true
+				continue
+			}
+			r.start = start
+			if endStr == "" {
+				r.length = size - start
+			} else {
+				end, err := strconv.ParseInt(endStr, 10, 64)
+				if err != nil || start > end {
+					return nil, errInvalidRange
+				}
+				if end >= size {
+					end = size - 1
+				}
+				r.length = end - start + 1
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		if noOverlap {
+			return nil, errNoOverlap
+		}
+		return nil, errInvalidRange
+	}
+	return ranges, nil
+}
+
+//seekerOf returns the io.ReadSeeker behind r (also if it was made closable with MakeClosable) or nil
+func seekerOf(r io.Reader) io.ReadSeeker {
+	if c, ok := r.(*closer); ok {
+		r = c.reader
+	}
+	rs, _ := r.(io.ReadSeeker)
+	return rs
+}
+
+//ifRangeMatches returns true if the request has no If-Range header or it matches the ETag (strong comparison) or the Last-Modified time of the response
+func ifRangeMatches(u WebUnit) bool {
+	ifRange := strings.TrimSpace(u.Request.Header.Get(HeaderKeyIfRange))
+	if ifRange == "" {
+		return true
+	}
+	header := u.Writer.Header()
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, `W/"`) {
+		etag := header.Get(HeaderKeyETag)
+		return etag != "" && etagMatches(ifRange, etag, true)
+	}
+	t, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(header.Get(HeaderKeyLastModified))
+	return err == nil && lastModified.Equal(t)
+}
+
+//selectRange advertises range support for seekable content of known size and applies the Range header of GET requests
+//It returns the reader for the selected range(s) and 206 (Partial Content), or r and 200 if the whole content is served.
+//Unsatisfiable ranges result in a StatusError with 416 (Requested Range Not Satisfiable), malformed Range headers are ignored
+func (c *content) selectRange(u WebUnit, r io.Reader) (io.Reader, int, error) {
+	rs := seekerOf(c.reader)
+	if rs == nil || c.size < 0 {
+		return r, http.StatusOK, nil
+	}
+	header := u.Writer.Header()
+	header.Set(HeaderKeyAcceptRanges, "bytes")
+	rangeHeader := u.Request.Header.Get(HeaderKeyRange)
+	if rangeHeader == "" || (u.Request.Method != http.MethodGet && u.Request.Method != http.MethodHead) || !ifRangeMatches(u) {
+		return r, http.StatusOK, nil
+	}
+	ranges, err := parseRange(rangeHeader, c.size)
+	if err == errNoOverlap {
+		header.Set(HeaderKeyContentRange, fmt.Sprintf("bytes */%d", c.size))
+		return nil, 0, NewStatusError(http.StatusRequestedRangeNotSatisfiable, "requested range not satisfiable: %s", rangeHeader)
+	}
+	var total int64
+	for _, ra := range ranges {
+		total += ra.length
+	}
+	if err != nil || total > c.size {
+		//malformed or overly expensive ranges are ignored
+		return r, http.StatusOK, nil
+	}
+	base, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(ranges) == 1 {
+		ra := ranges[0]
+		header.Set(HeaderKeyContentRange, ra.contentRange(c.size))
+		header.Set(HeaderKeyContentLength, strconv.FormatInt(ra.length, 10))
+		return &seekSection{rs, base + ra.start, ra.length, false}, http.StatusPartialContent, nil
+	}
+
+	var buffer bytes.Buffer
+	mw := multipart.NewWriter(&buffer)
+	contentType := header.Get(HeaderKeyContentType)
+	readers := make([]io.Reader, 0, 2*len(ranges)+1)
+	length := int64(0)
+	for _, ra := range ranges {
+		partHeader := textproto.MIMEHeader{HeaderKeyContentRange: {ra.contentRange(c.size)}}
+		if contentType != "" {
+			partHeader.Set(HeaderKeyContentType, contentType)
+		}
+		if _, err := mw.CreatePart(partHeader); err != nil {
+			return nil, 0, err
+		}
+		readers = append(readers, bytes.NewReader(append([]byte{}, buffer.Bytes()...)), &seekSection{rs, base + ra.start, ra.length, false})
+		length += int64(buffer.Len()) + ra.length
+		buffer.Reset()
+	}
+	mw.Close()
+	readers = append(readers, bytes.NewReader(buffer.Bytes()))
+	length += int64(buffer.Len())
+
+	header.Set(HeaderKeyContentType, "multipart/byteranges; boundary="+mw.Boundary())
+	header.Set(HeaderKeyContentLength, strconv.FormatInt(length, 10))
+	return io.MultiReader(readers...), http.StatusPartialContent, nil
+}
+
+//seekSection reads n bytes from offset of rs, seeking to offset on the first Read()
+type seekSection struct {
+	rs     io.ReadSeeker
+	offset int64
+	n      int64
+	seeked bool
+}
+
+func (s *seekSection) Read(p []byte) (int, error) {
+	if !s.seeked {
+		s.seeked = true
+		if _, err := s.rs.Seek(s.offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+	if s.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > s.n {
+		p = p[:s.n]
+	}
+	n, err := s.rs.Read(p)
+	s.n -= int64(n)
+	if err == io.EOF && s.n > 0 {
+		err = io.ErrUnexpectedEOF
+	} else if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
@@ -0,0 +1,286 @@
+package grest
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//Redirect responds with the redirect status code (301, 302, 303, 307 or 308) and sets the Location header to target
+//Relative targets like "../login" or "?page=2" are resolved against the path the client requested (also below StripPrefix)
+func Redirect(target string, code int) WebPart {
+	return func(u WebUnit) *WebUnit {
+		if u.GetPanic() != nil {
+			return servePanic(u)
+		}
+		if !isRedirect(code) {
+			u.Panic(NewStatusError(http.StatusInternalServerError, "%d is not a redirect status code", code))
+			return servePanic(u)
+		}
+		location, err := resolveLocation(u, target)
+		if err != nil {
+			u.Panic(NewStatusError(http.StatusInternalServerError, "invalid redirect target %q: %v", target, err))
+			return servePanic(u)
+		}
+		return serveRedirect(u, location, code)
+	}
+}
+
+//Redirect responds with the redirect status code (301, 302, 303, 307 or 308) and sets the Location header to target
+//Relative targets like "../login" or "?page=2" are resolved against the path the client requested (also below StripPrefix)
+func (w WebPart) Redirect(target string, code int) WebPart {
+	return Compose(w, Redirect(target, code))
+}
+
+//RouteNames is a registry of named route patterns that RedirectTo and URLFor build URLs from (see RouteIn)
+//Independent route trees (e.g. in tests) use their own RouteNames, so they can use the same names for different patterns
+type RouteNames struct {
+	mu       sync.RWMutex
+	patterns map[string]string
+}
+
+//NewRouteNames creates an empty RouteNames registry
+func NewRouteNames() *RouteNames {
+	return &RouteNames{patterns: map[string]string{}}
+}
+
+//DefaultRouteNames is used by Route, RedirectTo and URLFor, its names are shared by all routes of the process
+var DefaultRouteNames = NewRouteNames()
+
+//URLFor returns the path of the route registered with RouteIn(n, name, ...) with its {parameters} replaced by the (escaped) values of params
+func (n *RouteNames) URLFor(name string, params Data) (string, error) {
+	n.mu.RLock()
+	pattern, ok := n.patterns[name]
+	n.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("route %q is not registered", name)
+	}
+	parts := strings.Split(pattern, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			value, ok := params[p[1:len(p)-1]]
+			if !ok {
+				return "", fmt.Errorf("route %q needs the parameter %q", name, p[1:len(p)-1])
+			}
+			parts[i] = url.PathEscape(fmt.Sprint(value))
+		}
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+//Route matches paths against pattern like ParamPath and registers it under name in DefaultRouteNames, so RedirectTo and URLFor can build its URL
+//The names are global, Route panics if name is already registered with a different pattern (use RouteIn for independent route trees)
+func Route(name, pattern string) WebPart {
+	return RouteIn(DefaultRouteNames, name, pattern)
+}
+
+//Route matches paths against pattern like ParamPath and registers it under name in DefaultRouteNames, so RedirectTo and URLFor can build its URL
+//The names are global, Route panics if name is already registered with a different pattern (use RouteIn for independent route trees)
+func (w WebPart) Route(name, pattern string) WebPart {
+	return Compose(w, Route(name, pattern))
+}
+
+//RouteIn works like Route but registers the name in names
+//RouteIn panics if name is already registered in names with a different pattern
+func RouteIn(names *RouteNames, name, pattern string) WebPart {
+	names.mu.Lock()
+	defer names.mu.Unlock()
+	if existing, ok := names.patterns[name]; ok && existing != pattern {
+		panic(fmt.Sprintf("grest: route %q is already registered with pattern %q", name, existing))
+	}
+	names.patterns[name] = pattern
+	return ParamPath(pattern)
+}
+
+//RouteIn works like Route but registers the name in names
+//RouteIn panics if name is already registered in names with a different pattern
+func (w WebPart) RouteIn(names *RouteNames, name, pattern string) WebPart {
+	return Compose(w, RouteIn(names, name, pattern))
+}
+
+//URLFor returns the path of the route registered with Route(name, ...) with its {parameters} replaced by the (escaped) values of params
+func URLFor(name string, params Data) (string, error) {
+	return DefaultRouteNames.URLFor(name, params)
+}
+
+//RedirectTo redirects (302) to the route registered with Route(routeName, ...), see URLFor
+//The prefix removed by StripPrefix from the current request is put in front, so routes below StripPrefix can redirect to each other
+func RedirectTo(routeName string, params Data) WebPart {
+	return RedirectToIn(DefaultRouteNames, routeName, params)
+}
+
+//RedirectTo redirects (302) to the route registered with Route(routeName, ...), see URLFor
+//The prefix removed by StripPrefix from the current request is put in front, so routes below StripPrefix can redirect to each other
+func (w WebPart) RedirectTo(routeName string, params Data) WebPart {
+	return Compose(w, RedirectTo(routeName, params))
+}
+
+//RedirectToIn works like RedirectTo for a route registered with RouteIn(names, routeName, ...)
+func RedirectToIn(names *RouteNames, routeName string, params Data) WebPart {
+	return func(u WebUnit) *WebUnit {
+		if u.GetPanic() != nil {
+			return servePanic(u)
+		}
+		location, err := names.URLFor(routeName, params)
+		if err != nil {
+			u.Panic(NewStatusError(http.StatusInternalServerError, "%v", err))
+			return servePanic(u)
+		}
+		return Redirect(strippedPrefix(u)+location, http.StatusFound)(u)
+	}
+}
+
+//RedirectToIn works like RedirectTo for a route registered with RouteIn(names, routeName, ...)
+func (w WebPart) RedirectToIn(names *RouteNames, routeName string, params Data) WebPart {
+	return Compose(w, RedirectToIn(names, routeName, params))
+}
+
+//HTTPSOptions configure the HTTPS upgrade of RedirectHTTPSWith
+type HTTPSOptions struct {
+	//Port of the HTTPS server (0 = 443)
+	Port uint16
+	//TrustForwardedProto takes requests with "X-Forwarded-Proto: https" as HTTPS requests
+	//Only enable it behind a proxy that sets this header, otherwise clients can skip the redirect
+	TrustForwardedProto bool
+}
+
+//RedirectHTTPS redirects (308) plain HTTP requests to the same URL with https on the given port (0 = 443)
+//HTTPS requests are filtered, so it can be the first option of Choose (use RedirectHTTPSWith behind a proxy that terminates TLS)
+func RedirectHTTPS(port uint16) WebPart {
+	return RedirectHTTPSWith(HTTPSOptions{Port: port})
+}
+
+//RedirectHTTPS redirects (308) plain HTTP requests to the same URL with https on the given port (0 = 443)
+//HTTPS requests are filtered, so it can be the first option of Choose (use RedirectHTTPSWith behind a proxy that terminates TLS)
+func (w WebPart) RedirectHTTPS(port uint16) WebPart {
+	return Compose(w, RedirectHTTPS(port))
+}
+
+//RedirectHTTPSWith works like RedirectHTTPS with the given options
+func RedirectHTTPSWith(options HTTPSOptions) WebPart {
+	return func(u WebUnit) *WebUnit {
+		if u.Request.TLS != nil || (options.TrustForwardedProto && strings.EqualFold(u.Request.Header.Get(HeaderKeyXForwardedProto), "https")) {
+			return nil
+		}
+		host := u.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+			host = "[" + host + "]"
+		}
+		if options.Port != 0 && options.Port != 443 {
+			host += ":" + strconv.Itoa(int(options.Port))
+		}
+		return serveRedirect(u, "https://"+host+u.Request.URL.RequestURI(), http.StatusPermanentRedirect)
+	}
+}
+
+//RedirectHTTPSWith works like RedirectHTTPS with the given options
+func (w WebPart) RedirectHTTPSWith(options HTTPSOptions) WebPart {
+	return Compose(w, RedirectHTTPSWith(options))
+}
+
+//RedirectMap redirects (301) requests whose path is a key of table to the path or URL of its value, e.g. to migrate legacy URLs (see LoadRedirectMap)
+//The paths are compared like Path does, the query of the request is kept if the target has none. Other requests are filtered
+func RedirectMap(table map[string]string) WebPart {
+	cleaned := make(map[string]string, len(table))
+	for from, to := range table {
+		cleaned[Clean(from)] = to
+	}
+	return func(u WebUnit) *WebUnit {
+		target, ok := cleaned[Clean(u.Request.URL.Path)]
+		if !ok {
+			return nil
+		}
+		if u.Request.URL.RawQuery != "" && !strings.Contains(target, "?") {
+			target += "?" + u.Request.URL.RawQuery
+		}
+		return Redirect(target, http.StatusMovedPermanently)(u)
+	}
+}
+
+//RedirectMap redirects (301) requests whose path is a key of table to the path or URL of its value, e.g. to migrate legacy URLs (see LoadRedirectMap)
+//The paths are compared like Path does, the query of the request is kept if the target has none. Other requests are filtered
+func (w WebPart) RedirectMap(table map[string]string) WebPart {
+	return Compose(w, RedirectMap(table))
+}
+
+//LoadRedirectMap reads a table for RedirectMap with one "old-path new-path-or-url" pair per line (separated by whitespace)
+//Empty lines and lines starting with # are ignored
+func LoadRedirectMap(r io.Reader) (map[string]string, error) {
+	table := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("redirect table line %d: expected 2 columns but found %d", line, len(fields))
+		}
+		table[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+//=== Helpers =====================================================================================
+
+//isRedirect returns true for the status codes that can be used with Location
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+//strippedPrefix returns the prefix StripPrefix removed from the path of the request ("" if there is none)
+func strippedPrefix(u WebUnit) string {
+	original, current := requestPath(u), u.Request.URL.Path
+	if current == "" || len(original) <= len(current) || !strings.HasSuffix(original, current) {
+		return ""
+	}
+	return strings.TrimSuffix(original[:len(original)-len(current)], "/")
+}
+
+//resolveLocation resolves target against the path the client requested, absolute URLs are kept
+func resolveLocation(u WebUnit, target string) (string, error) {
+	ref, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	if ref.IsAbs() || ref.Host != "" {
+		return ref.String(), nil
+	}
+	base := &url.URL{Path: requestPath(u), RawQuery: u.Request.URL.RawQuery}
+	return base.ResolveReference(ref).String(), nil
+}
+
+//serveRedirect sets the Location header and responds with code (with a short HTML body for GET requests like http.Redirect)
+func serveRedirect(u WebUnit, location string, code int) *WebUnit {
+	header := u.Writer.Header()
+	header.Set(HeaderKeyLocation, location)
+	if u.Request.Method == http.MethodGet || u.Request.Method == http.MethodHead {
+		body := `<a href="` + html.EscapeString(location) + `">` + http.StatusText(code) + "</a>.\n"
+		header.Set(HeaderKeyContentType, contentTypeHTMLUTF8)
+		header.Set(HeaderKeyContentLength, strconv.Itoa(len(body)))
+		u.Writer.WriteHeader(code)
+		u.Writer.Write([]byte(body))
+		return &u
+	}
+	header.Del(HeaderKeyContentLength)
+	u.Writer.WriteHeader(code)
+	return &u
+}
@@ -0,0 +1,82 @@
+package grest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	cases := []struct {
+		part     WebPart
+		target   string
+		header   http.Header
+		code     int
+		location string
+	}{
+		{Redirect("../login", http.StatusSeeOther), "/app/settings/", nil, http.StatusSeeOther, "/app/login"},
+		{Redirect("?page=2", http.StatusFound), "/list?page=1", nil, http.StatusFound, "/list?page=2"},
+		{StripPrefix("/api").Redirect("other", http.StatusFound), "/api/v1/item", nil, http.StatusFound, "/api/v1/other"},
+		{Redirect("https://example.com/x", http.StatusMovedPermanently), "/", nil, http.StatusMovedPermanently, "https://example.com/x"},
+		{Redirect("/x", http.StatusOK), "/", nil, http.StatusInternalServerError, ""},
+		{Route("user-post", "/users/{id}/posts/{post}").RedirectTo("user-post", Data{"id": 5, "post": "a b"}), "/users/1/posts/x", nil, http.StatusFound, "/users/5/posts/a%20b"},
+		{RedirectTo("missing", nil), "/", nil, http.StatusInternalServerError, ""},
+		{RedirectHTTPS(0), "http://example.com:80/a?b=c", nil, http.StatusPermanentRedirect, "https://example.com/a?b=c"},
+		{RedirectHTTPS(8443), "http://example.com/a", nil, http.StatusPermanentRedirect, "https://example.com:8443/a"},
+		{RedirectMap(map[string]string{"/Old/Page/": "/new"}), "/old/page?x=1", nil, http.StatusMovedPermanently, "/new?x=1"},
+		{RedirectHTTPS(0), "http://[::1]/a", nil, http.StatusPermanentRedirect, "https://[::1]/a"},
+		{RedirectHTTPS(0), "http://example.com/a", http.Header{HeaderKeyXForwardedProto: {"https"}}, http.StatusPermanentRedirect, "https://example.com/a"},
+		{StripPrefix("/api").Route("api-user", "/users/{id}").RedirectTo("api-user", Data{"id": 5}), "/api/users/1", nil, http.StatusFound, "/api/users/5"},
+	}
+	for i, c := range cases {
+		u, w := getTestRecorder(http.MethodGet, c.target, c.header)
+		if c.part(u) == nil {
+			t.Errorf("case %d should not be filtered", i)
+			continue
+		}
+		if w.Code != c.code || w.Header().Get(HeaderKeyLocation) != c.location {
+			t.Errorf("case %d should be %d %q but was %d %q", i, c.code, c.location, w.Code, w.Header().Get(HeaderKeyLocation))
+		}
+	}
+
+	u, _ := getTestRecorder(http.MethodGet, "/a", http.Header{HeaderKeyXForwardedProto: {"https"}})
+	if RedirectHTTPSWith(HTTPSOptions{TrustForwardedProto: true})(u) != nil {
+		t.Errorf("RedirectHTTPSWith should filter HTTPS requests behind a trusted proxy")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Route should panic if the name is registered with another pattern")
+			}
+		}()
+		Route("user-post", "/posts/{post}")
+	}()
+
+	//independent route trees can use the same names
+	admin, shop := NewRouteNames(), NewRouteNames()
+	RouteIn(admin, "item", "/admin/items/{id}")
+	u, w := getTestRecorder(http.MethodGet, "/shop/items/1", nil)
+	RouteIn(shop, "item", "/shop/items/{id}").RedirectToIn(shop, "item", Data{"id": 2})(u)
+	if w.Header().Get(HeaderKeyLocation) != "/shop/items/2" {
+		t.Errorf("RedirectToIn should use the pattern of its own registry but was %q", w.Header().Get(HeaderKeyLocation))
+	}
+	if location, err := admin.URLFor("item", Data{"id": 3}); err != nil || location != "/admin/items/3" {
+		t.Errorf("URLFor should use the pattern of its own registry but was %q (%v)", location, err)
+	}
+	if _, err := URLFor("item", Data{"id": 3}); err == nil {
+		t.Errorf("names of other registries should not be in DefaultRouteNames")
+	}
+
+	u, _ = getTestRecorder(http.MethodGet, "/unknown", nil)
+	if RedirectMap(map[string]string{"/old": "/new"})(u) != nil {
+		t.Errorf("RedirectMap should filter unknown paths")
+	}
+
+	table, err := LoadRedirectMap(strings.NewReader("# legacy\n/old.php  /new\n\n/a https://example.com/b\n"))
+	if err != nil || len(table) != 2 || table["/old.php"] != "/new" || table["/a"] != "https://example.com/b" {
+		t.Errorf("LoadRedirectMap should read the table but was %v (%v)", table, err)
+	}
+	if _, err := LoadRedirectMap(strings.NewReader("/a /b /c\n")); err == nil {
+		t.Errorf("LoadRedirectMap should reject lines with 3 columns")
+	}
+}
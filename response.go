@@ -0,0 +1,177 @@
+package grest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+)
+
+//responseKey to save the *Response of Buffered() in the context
+const responseKey contextKey = "response"
+
+//Response records status, header and body written by the following WebParts instead of sending them right away (see Buffered())
+//Later WebParts can inspect and modify it until it is sent
+//Response does not implement http.Flusher: flushing a buffered response in the middle of a stream would send a Content-Length that does not match the body, the whole response is sent with Send() instead
+type Response struct {
+	//Code is the status code written by WriteHeader (0 = not written yet)
+	Code int
+	//Body holds the bytes written so far
+	Body *bytes.Buffer
+
+	writer  http.ResponseWriter
+	header  http.Header
+	initial http.Header
+	written bool
+	sent    bool
+	//hooks are called before the response is sent
+	hooks []func(*Response)
+}
+
+//Header returns the header of the response that is sent on Send()
+func (r *Response) Header() http.Header {
+	return r.header
+}
+
+//WriteHeader records the status code (only the first call counts like with http.ResponseWriter, calls after Send() are ignored)
+func (r *Response) WriteHeader(statusCode int) {
+	if r.sent {
+		return
+	}
+	if r.Code == 0 {
+		r.Code = statusCode
+	}
+	r.written = true
+}
+
+//Write appends data to the Body (after Send() data is written directly)
+func (r *Response) Write(data []byte) (int, error) {
+	if r.sent {
+		return r.writer.Write(data)
+	}
+	if r.Code == 0 {
+		r.Code = http.StatusOK
+	}
+	r.written = true
+	return r.Body.Write(data)
+}
+
+//Written returns true if anything was written to the Response
+func (r *Response) Written() bool {
+	return r.written
+}
+
+//Sent returns true if the Response was already sent
+func (r *Response) Sent() bool {
+	return r.sent
+}
+
+//Reset discards status, body and all headers set after Buffered()
+func (r *Response) Reset() {
+	r.Code = 0
+	r.Body.Reset()
+	r.header = r.initial.Clone()
+	r.written = false
+}
+
+//Send sends the recorded response with a Content-Length matching the Body (does nothing if nothing was written or it was already sent)
+func (r *Response) Send() {
+	if r.sent || !r.written {
+		return
+	}
+	r.sent = true
+	r.runHooks()
+	r.copyHeader()
+	header := r.writer.Header()
+	if bodyAllowed(r.Code) {
+		header.Set(HeaderKeyContentLength, strconv.Itoa(r.Body.Len()))
+	}
+	r.writer.WriteHeader(r.Code)
+	r.writer.Write(r.Body.Bytes())
+}
+
+//copyHeader replaces the header of the wrapped writer with the header of the Response
+func (r *Response) copyHeader() {
+	header := r.writer.Header()
+	for k := range header {
+		delete(header, k)
+	}
+	for k, v := range r.header {
+		header[k] = v
+	}
+}
+
+//runHooks calls the functions registered with beforeSend (only once)
+func (r *Response) runHooks() {
+	hooks := r.hooks
+	r.hooks = nil
+	for _, hook := range hooks {
+		hook(r)
+	}
+}
+
+//beforeSend registers a function that can modify the recorded response right before it is sent
+func (r *Response) beforeSend(hook func(*Response)) {
+	r.hooks = append(r.hooks, hook)
+}
+
+//Buffered records the response of the following WebParts in a Response that can be read and modified with u.Response() until it is flushed
+//The Response is flushed by Flush() or by the router when the routes returned (call Flush() yourself without the router, see NewWebUnit)
+func Buffered() WebPart {
+	return func(u WebUnit) *WebUnit {
+		if u.Response() != nil {
+			return &u
+		}
+		r := &Response{Body: &bytes.Buffer{}, writer: u.Writer, initial: u.Writer.Header().Clone()}
+		r.header = r.initial.Clone()
+		u.Writer = r
+		u.Context = context.WithValue(u.Context, responseKey, r)
+		return &u
+	}
+}
+
+//Buffered records the response of the following WebParts in a Response that can be read and modified with u.Response() until it is flushed
+//The Response is flushed by Flush() or by the router when the routes returned (call Flush() yourself without the router, see NewWebUnit)
+func (w WebPart) Buffered() WebPart {
+	return Compose(w, Buffered())
+}
+
+//Response returns the Response recorded since Buffered() or nil if the response is not buffered
+func (u WebUnit) Response() *Response {
+	r, _ := u.Context.Value(responseKey).(*Response)
+	return r
+}
+
+//Flush sends the Response recorded since Buffered()
+//If the WebUnit is in panic the recorded response is discarded and the panic is served instead,
+//so errors that happen after the body was written still result in a proper error response
+func Flush() WebPart {
+	return func(u WebUnit) *WebUnit {
+		r := u.Response()
+		if r == nil || r.Sent() {
+			return &u
+		}
+		if u.GetPanic() != nil {
+			r.Reset()
+			servePanic(u)
+			//nothing was sent yet, so there is no need to abort the connection
+			u.Context = context.WithValue(u.Context, abortKey, abortState(false))
+		}
+		r.Send()
+		return &u
+	}
+}
+
+//Flush sends the Response recorded since Buffered()
+//If the WebUnit is in panic the recorded response is discarded and the panic is served instead,
+//so errors that happen after the body was written still result in a proper error response
+func (w WebPart) Flush() WebPart {
+	return Compose(w, Flush())
+}
+
+//=== Helpers =====================================================================================
+
+//bodyAllowed returns false for status codes that must not have a body
+func bodyAllowed(status int) bool {
+	return !(status >= 100 && status < 200) && status != http.StatusNoContent && status != http.StatusNotModified
+}
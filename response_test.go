@@ -0,0 +1,78 @@
+package grest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBuffered(t *testing.T) {
+	routes := Compose(Buffered().ServeString("hello"), func(u WebUnit) *WebUnit {
+		r := u.Response()
+		if r == nil || r.Code != http.StatusOK || r.Body.String() != "hello" {
+			t.Errorf("response should be recorded but was %+v", r)
+			return &u
+		}
+		r.Body.WriteString(" world")
+		r.Header().Set("X-Seen", r.Header().Get(HeaderKeyContentType))
+		return &u
+	})
+	w := httptest.NewRecorder()
+	router{routes}.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "hello world" || w.Header().Get(HeaderKeyContentLength) != "11" || w.Header().Get("X-Seen") != contentTypeTextUTF8 {
+		t.Errorf("modified response should be sent but was %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	//late read errors become a proper 500 instead of an aborted connection
+	routes = Buffered().ServeReadCloser(func(WebUnit) (io.ReadCloser, error) {
+		return MakeClosable(&failingReader{ResponseBufferSize * 2}, nil), nil
+	})
+	w = httptest.NewRecorder()
+	router{routes}.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError || w.Body.String() != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("buffered response with read error should be 500 but was %d", w.Code)
+	}
+
+	//streaming parts cannot flush a buffered response before it is complete
+	routes = Compose(Buffered().ServeString("part1 "), func(u WebUnit) *WebUnit {
+		if f, ok := u.Writer.(http.Flusher); ok {
+			f.Flush()
+		}
+		u.Writer.Write([]byte("part2"))
+		return &u
+	})
+	w = httptest.NewRecorder()
+	router{routes}.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Body.String() != "part1 part2" || w.Header().Get(HeaderKeyContentLength) != "11" {
+		t.Errorf("buffered stream should be sent completely but was %q with Content-Length %s", w.Body.String(), w.Header().Get(HeaderKeyContentLength))
+	}
+
+	//nothing written means nothing flushed
+	u, rec := getTestRecorder(http.MethodGet, "/", nil)
+	Buffered().Flush()(u)
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 || rec.Header().Get(HeaderKeyContentLength) != "" {
+		t.Errorf("empty response should not be flushed but was %d %v", rec.Code, rec.Header())
+	}
+
+	//WriteHeader after Flush() is not forwarded again
+	u, rec = getTestRecorder(http.MethodGet, "/", nil)
+	counter := &headerCounter{ResponseWriter: rec}
+	u.Writer = counter
+	result := Buffered().ServeString("a").Flush()(u)
+	result.Writer.WriteHeader(http.StatusInternalServerError)
+	if counter.calls != 1 || rec.Code != http.StatusOK {
+		t.Errorf("WriteHeader after Flush() should be ignored but was called %d times", counter.calls)
+	}
+}
+
+//headerCounter counts the calls of WriteHeader
+type headerCounter struct {
+	http.ResponseWriter
+	calls int
+}
+
+func (c *headerCounter) WriteHeader(status int) {
+	c.calls++
+	c.ResponseWriter.WriteHeader(status)
+}
@@ -2,19 +2,97 @@ package grest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"sync"
 )
 
 type router struct {
 	routes WebPart
 }
 
+//deferKey to save the functions that are called after the request was handled
+const deferKey contextKey = "defer"
+
+//deferred holds the functions registered with WebUnit.Defer
+type deferred struct {
+	mu    sync.Mutex
+	funcs []func()
+}
+
+//ErrNoDefer is returned by WebUnit.Defer if the WebUnit was not created by the router or NewWebUnit
+var ErrNoDefer = errors.New("the WebUnit has no list of deferred functions (see NewWebUnit)")
+
+//NewWebUnit creates a WebUnit like the router does, for calling WebParts without StartListening (e.g. in tests or an own http.Handler)
+//done has to be called after the WebParts returned, it calls the functions registered with Defer
+func NewWebUnit(w http.ResponseWriter, req *http.Request) (u WebUnit, done func()) {
+	ctx := context.WithValue(req.Context(), deferKey, &deferred{})
+	return WebUnit{w, req, ctx}, func() { runDeferred(ctx) }
+}
+
+//Defer registers the cleanup function f to be called after the routes finished handling the request (even if they panic).
+//Like with the defer statement the last registered function is called first.
+//If a Choose option that registered f is rejected, f is called right away when Choose continues with the next option.
+//Returns ErrNoDefer if the WebUnit was not created by the router or NewWebUnit, f is never called in that case
+func (u WebUnit) Defer(f func()) error {
+	d, ok := u.Context.Value(deferKey).(*deferred)
+	if !ok {
+		return ErrNoDefer
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.funcs = append(d.funcs, f)
+	return nil
+}
+
+//runDeferred calls all functions registered with WebUnit.Defer in reverse order
+func runDeferred(ctx context.Context) {
+	if d, ok := ctx.Value(deferKey).(*deferred); ok {
+		d.rollback(0)
+	}
+}
+
+//deferMark returns the number of functions registered with WebUnit.Defer so far (see rollback)
+func deferMark(u WebUnit) int {
+	d, ok := u.Context.Value(deferKey).(*deferred)
+	if !ok {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.funcs)
+}
+
+//rollback calls the functions registered after mark in reverse order and removes them
+func (d *deferred) rollback(mark int) {
+	d.mu.Lock()
+	if mark >= len(d.funcs) {
+		d.mu.Unlock()
+		return
+	}
+	funcs := d.funcs[mark:]
+	d.funcs = d.funcs[:mark]
+	d.mu.Unlock()
+	for i := len(funcs) - 1; i >= 0; i-- {
+		funcs[i]()
+	}
+}
+
+//rollbackDeferred calls the functions that were registered by a rejected WebPart (see deferMark)
+func rollbackDeferred(u WebUnit, mark int) {
+	if d, ok := u.Context.Value(deferKey).(*deferred); ok {
+		d.rollback(mark)
+	}
+}
+
 func (r router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	ctx, cancel := context.WithCancel(req.Context())
+	ctx = context.WithValue(ctx, deferKey, &deferred{})
 
 	defer func() {
+		runDeferred(ctx)
 		err := recover()
 		if err != nil {
 			cancel()
@@ -28,7 +106,19 @@ func (r router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			panic(err)
 		}
 	}()
-	r.routes(WebUnit{w, req, ctx})
+	result := r.routes(WebUnit{w, req, ctx})
+	if result != nil && result.Response() != nil {
+		result = Flush()(*result)
+	}
+	if result != nil {
+		if nw, ok := result.Context.Value(noContentKey).(*noBodyWriter); ok {
+			nw.finish()
+		}
+	}
+	if result != nil && result.aborted() {
+		//the response body is incomplete, abort the connection so the client does not take it as complete
+		panic(http.ErrAbortHandler)
+	}
 }
 
 //StartListening starts a HTTP listener on given port
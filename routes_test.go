@@ -0,0 +1,38 @@
+package grest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDefer(t *testing.T) {
+	var calls []string
+	register := func(name string) WebPart {
+		return func(u WebUnit) *WebUnit {
+			if err := u.Defer(func() { calls = append(calls, name) }); err != nil {
+				t.Fatal(err)
+			}
+			return &u
+		}
+	}
+	routes := Choose(
+		Compose(register("rejected"), Path("/a")),
+		Compose(register("first"), register("second"), Path("/b")),
+	)
+
+	u, done := NewWebUnit(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/b", nil))
+	routes(u)
+	if len(calls) != 1 || calls[0] != "rejected" {
+		t.Errorf("cleanup of a rejected option should run right away but was %v", calls)
+	}
+	done()
+	if len(calls) != 3 || calls[1] != "second" || calls[2] != "first" {
+		t.Errorf("deferred functions should run in reverse order when done but was %v", calls)
+	}
+
+	u = getTestContext("http://text.de/")
+	if err := u.Defer(func() {}); err != ErrNoDefer {
+		t.Errorf("Defer without deferred functions should return ErrNoDefer but was %v", err)
+	}
+}
@@ -2,98 +2,91 @@ package grest
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"mime"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 )
 
 //ServeReadCloser returns a HTTP response with Content coming from a io.ReadCloser that is closed after Read() returns io.EOF
 //If getReader() returns an error it will result in a panic
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
+//The Content-Type is detected from the first bytes if it was not set before (see ContentType(...)), the Content-Length is set if the size of the reader is known
+//If the reader is an io.ReadSeeker of known size (e.g. a bytes.Reader or os.File) Range requests are answered with 206 (Partial Content), see ServeFile
 //Try to create the reader inside the getReader func to avoid too soon/unnecessary memory allocation
 func ServeReadCloser(getReader func(WebUnit) (io.ReadCloser, error)) WebPart {
-	return func(u WebUnit) *WebUnit {
+	return serveContent(func(u WebUnit) (*content, error) {
 		r, err := getReader(u)
 		if err != nil {
-			u.Panic(err)
-			u.Writer.WriteHeader(http.StatusInternalServerError)
-			u.Writer.Write([]byte(err.Error()))
-			return &u
-		}
-		defer r.Close()
-		status := u.GetStatus()
-		if status == 0 {
-			status = http.StatusOK
-		}
-		u.Writer.WriteHeader(status)
-		_, err = io.Copy(u.Writer, r)
-		if err != nil {
-			u.Writer.WriteHeader(http.StatusInternalServerError)
-			u.Writer.Write([]byte(err.Error()))
+			return nil, err
 		}
-
-		return &u
-	}
+		return &content{reader: r, size: sizeOf(r)}, nil
+	})
 }
 
 //ServeReadCloser returns a HTTP response with Content coming from a io.ReadCloser that is closed after Read() returns io.EOF
 //If getReader() returns an error it will result in a panic
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
 func (w WebPart) ServeReadCloser(getReader func(WebUnit) (io.ReadCloser, error)) WebPart {
 	return Compose(w, ServeReadCloser(getReader))
 }
 
 // ServeBytes responses with the given bytes
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
 func ServeBytes(data []byte) WebPart {
-	return ServeReadCloser(func(WebUnit) (io.ReadCloser, error) { return MakeClosable(bytes.NewReader(data), nil), nil })
+	return serveBytes("", func(WebUnit) ([]byte, error) { return data, nil })
 }
 
 // ServeBytes responses with the given bytes
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
 func (w WebPart) ServeBytes(data []byte) WebPart {
 	return Compose(w, ServeBytes(data))
 }
 
 // ServeBytesLazy responses with the given bytes
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
 func ServeBytesLazy(getData func(WebUnit) ([]byte, error)) WebPart {
-	return ServeReadCloser(func(u WebUnit) (io.ReadCloser, error) {
-		data, err := getData(u)
-		if err != nil {
-			return nil, err
-		}
-		return MakeClosable(bytes.NewReader(data), nil), nil
-	})
+	return serveBytes("", getData)
 }
 
 // ServeBytesLazy responses with the given bytes
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
 func (w WebPart) ServeBytesLazy(getData func(WebUnit) ([]byte, error)) WebPart {
 	return Compose(w, ServeBytesLazy(getData))
 }
 
-//ServeString serves the given string as response (convinience wrapper for ServeBytes)
+//ServeString serves the given string as response (convinience wrapper for ServeBytes) with "Content-Type: text/plain; charset=utf-8"
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
 func ServeString(s string) WebPart {
-	return ServeBytes([]byte(s))
+	return serveBytes(contentTypeTextUTF8, func(WebUnit) ([]byte, error) { return []byte(s), nil })
 }
 
-//ServeString serves the given string as response (convinience wrapper for ServeBytes)
+//ServeString serves the given string as response (convinience wrapper for ServeBytes) with "Content-Type: text/plain; charset=utf-8"
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
 func (w WebPart) ServeString(s string) WebPart {
-	return w.ServeBytes([]byte(s))
+	return Compose(w, ServeString(s))
 }
 
-//ServeJSON responses with a JSON object as bytes
+//ServeJSON responses with a JSON object as bytes with "Content-Type: application/json"
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
 func ServeJSON(obj interface{}) WebPart {
-	return ServeReadCloser(func(WebUnit) (io.ReadCloser, error) {
-		data, err := json.Marshal(obj)
-		if err != nil {
-			return nil, err
-		}
-		return MakeClosable(bytes.NewReader(data), nil), nil
-	})
+	return serveBytes(ContentTypeJSON, func(WebUnit) ([]byte, error) { return json.Marshal(obj) })
 }
 
-//ServeJSON responses with a JSON object as bytes
+//ServeJSON responses with a JSON object as bytes with "Content-Type: application/json"
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
 func (w WebPart) ServeJSON(obj interface{}) WebPart {
 	return Compose(w, ServeJSON(obj))
 }
 
 //ServeExtrasAsJSON is a convinience call that converts the current Extras into a JSON object and returns it with the current status (default 200 OK)
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
 func ServeExtrasAsJSON() WebPart {
 	return func(u WebUnit) *WebUnit {
 		return ServeJSON(u.Extras())(u)
@@ -101,11 +94,211 @@ func ServeExtrasAsJSON() WebPart {
 }
 
 //ServeExtrasAsJSON is a convinience call that converts the current Extras into a JSON object and returns it with the current status (default 200 OK)
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
 func (w WebPart) ServeExtrasAsJSON() WebPart {
 	return Compose(w, ServeExtrasAsJSON())
 }
 
 //=== Helpers =====================================================================================
+
+//abortKey to save the abortState of a WebUnit in the context
+const abortKey contextKey = "abort"
+
+//abortState true marks a WebUnit whose response body could not be written completely, the router aborts the connection for such WebUnits
+type abortState bool
+
+//aborted returns true if the connection has to be aborted because the response body is incomplete
+func (u WebUnit) aborted() bool {
+	a, _ := u.Context.Value(abortKey).(abortState)
+	return bool(a)
+}
+
+//ResponseBufferSize is the number of bytes the serving WebParts read before the response header is written
+//Errors that occur while reading bodies up to this size result in a proper error response, bodies up to this size get a Content-Length
+const ResponseBufferSize = 64 << 10
+
+//contentTypeTextUTF8 is used for plain text responses
+const contentTypeTextUTF8 = ContentTypeText + "; charset=utf-8"
+
+//sniffLen is the number of bytes used to detect the Content-Type (see http.DetectContentType)
+const sniffLen = 512
+
+//content is a response body with its meta data
+type content struct {
+	reader io.ReadCloser
+	//contentType is used if the Content-Type header was not set before ("" = detect by name or content)
+	contentType string
+	//name of the file (used to detect the Content-Type by extension)
+	name string
+	//size in bytes (-1 = unknown)
+	size int64
+	//modTime of files (used for ETag and Last-Modified, see ETags())
+	modTime time.Time
+	//data is the whole content if it is available in memory (used for ETag, see ETags())
+	data []byte
+	//hashes remembers the ETags of fs files without modification time (nil for other content)
+	hashes *hashCache
+}
+
+//serveContent is the base of all serving WebParts
+//The first ResponseBufferSize bytes are read before the header is written, so errors while reading small bodies still result in a proper error response.
+//If reading fails after the header was written the WebUnit panics and the connection is aborted (see abortKey) to not send a corrupted body
+//Conditional GET requests are answered with 304 (Not Modified) before the content is created if the validators are known already (see LastModified()), otherwise as soon as they are known (see ETags())
+func serveContent(getContent func(WebUnit) (*content, error)) WebPart {
+	return func(u WebUnit) *WebUnit {
+		if u.GetPanic() != nil {
+			return servePanic(u)
+		}
+		if notModified(u) {
+			return serveNotModified(u)
+		}
+		c, err := getContent(u)
+		if err != nil {
+			u.Panic(err)
+			return servePanic(u)
+		}
+		defer c.reader.Close()
+
+		c.setValidators(u)
+		if notModified(u) {
+			return serveNotModified(u)
+		}
+
+		r, err := c.setHeaders(u.Writer.Header())
+		if err != nil {
+			u.Panic(err)
+			return servePanic(u)
+		}
+		status := u.GetStatus()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status == http.StatusOK {
+			if r, status, err = c.selectRange(u, r); err != nil {
+				u.Panic(err)
+				return servePanic(u)
+			}
+		}
+		if !bodyAllowed(status) {
+			//e.g. Status(204), the content is dropped instead of failing to write it
+			u.Writer.Header().Del(HeaderKeyContentType)
+			u.Writer.Header().Del(HeaderKeyContentLength)
+			u.Writer.WriteHeader(status)
+			return &u
+		}
+
+		var head bytes.Buffer
+		_, err = io.CopyN(&head, r, ResponseBufferSize)
+		complete := err == io.EOF
+		if err != nil && !complete {
+			u.Panic(err)
+			return servePanic(u)
+		}
+		if complete && status == http.StatusOK {
+			setHashETag(u, head.Bytes())
+			if notModified(u) {
+				return serveNotModified(u)
+			}
+		}
+		if complete && u.Writer.Header().Get(HeaderKeyContentLength) == "" {
+			u.Writer.Header().Set(HeaderKeyContentLength, strconv.Itoa(head.Len()))
+		}
+
+		u.Writer.WriteHeader(status)
+		_, err = u.Writer.Write(head.Bytes())
+		if err == nil && !complete {
+			_, err = io.Copy(u.Writer, r)
+		}
+		if err != nil {
+			u.Panic(err)
+			u.Context = context.WithValue(u.Context, abortKey, abortState(true))
+		}
+
+		return &u
+	}
+}
+
+//serveBytes serves the result of getData with the given Content-Type ("" = detect from content)
+func serveBytes(contentType string, getData func(WebUnit) ([]byte, error)) WebPart {
+	return serveContent(func(u WebUnit) (*content, error) {
+		data, err := getData(u)
+		if err != nil {
+			return nil, err
+		}
+		return &content{reader: MakeClosable(bytes.NewReader(data), nil), contentType: contentType, size: int64(len(data)), data: data}, nil
+	})
+}
+
+//setHeaders sets Content-Type and Content-Length (if known) unless they were set before
+//The returned reader has to be used instead of c.reader because the first bytes may have been read to detect the Content-Type
+func (c *content) setHeaders(header http.Header) (io.Reader, error) {
+	var r io.Reader = c.reader
+	if header.Get(HeaderKeyContentType) == "" {
+		contentType := c.contentType
+		if contentType == "" && c.name != "" {
+			contentType = mime.TypeByExtension(filepath.Ext(c.name))
+		}
+		if contentType == "" {
+			head := make([]byte, sniffLen)
+			n, err := io.ReadFull(c.reader, head)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return nil, err
+			}
+			head = head[:n]
+			contentType = http.DetectContentType(head)
+			//seekable readers are rewound, so they can still be used for range requests
+			if rs := seekerOf(c.reader); rs == nil {
+				r = io.MultiReader(bytes.NewReader(head), c.reader)
+			} else if _, err := rs.Seek(-int64(n), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+		header.Set(HeaderKeyContentType, contentType)
+	}
+	if c.size >= 0 && header.Get(HeaderKeyContentLength) == "" {
+		header.Set(HeaderKeyContentLength, strconv.FormatInt(c.size, 10))
+	}
+	return r, nil
+}
+
+//sizeOf returns the number of bytes left in readers that know it (like bytes.Reader or os.File) or -1
+func sizeOf(r io.Reader) int64 {
+	if c, ok := r.(*closer); ok {
+		r = c.reader
+	}
+	switch t := r.(type) {
+	case interface{ Len() int }:
+		return int64(t.Len())
+	case *os.File:
+		info, err := t.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := t.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	}
+	return -1
+}
+
+//servePanic responds with the status and message of the current panic, leaving the WebUnit in panic
+//Only the message of a StatusError is sent to the client, other errors (e.g. with file paths or database messages) are answered with the status text
+func servePanic(u WebUnit) *WebUnit {
+	status := u.GetPanicStatus()
+	message := http.StatusText(status)
+	var se StatusError
+	if errors.As(u.GetPanic(), &se) {
+		message = se.Error()
+	}
+	u.Writer.Header().Set(HeaderKeyContentType, contentTypeTextUTF8)
+	u.Writer.Header().Del(HeaderKeyContentLength)
+	u.Writer.WriteHeader(status)
+	u.Writer.Write([]byte(message))
+	return &u
+}
+
 type closer struct {
 	reader io.Reader
 	close  func() error
@@ -0,0 +1,263 @@
+package grest
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getTestRecorder(method, target string, header http.Header) (WebUnit, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	return WebUnit{w, r, context.TODO()}, w
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		accept      string
+		contentType string
+		status      int
+	}{
+		{"", ContentTypeJSON, http.StatusOK},
+		{"*/*", ContentTypeJSON, http.StatusOK},
+		{"text/csv", ContentTypeCSV + "; charset=utf-8", http.StatusOK},
+		{"text/*", ContentTypeText + "; charset=utf-8", http.StatusOK},
+		{"application/json;q=0.5, text/csv", ContentTypeCSV + "; charset=utf-8", http.StatusOK},
+		{"text/*;q=0.9, text/csv;q=0.1", ContentTypeText + "; charset=utf-8", http.StatusOK},
+		{"*/*;q=0.1, application/json;q=0", ContentTypeText + "; charset=utf-8", http.StatusOK},
+		{"image/png", contentTypeTextUTF8, http.StatusNotAcceptable},
+	}
+
+	data := Datas{{"a": 1, "b": "x"}, {"a": 2}}
+	for _, c := range cases {
+		u, w := getTestRecorder(http.MethodGet, "/", http.Header{HeaderKeyAccept: []string{c.accept}})
+		Negotiate(data, JSONEncoder(), TextEncoder(), CSVEncoder())(u)
+		if w.Code != c.status || w.Header().Get(HeaderKeyContentType) != c.contentType {
+			t.Errorf("Accept: %s should result in %d %s but was %d %s", c.accept, c.status, c.contentType, w.Code, w.Header().Get(HeaderKeyContentType))
+		}
+		if w.Header().Get(HeaderKeyVary) != HeaderKeyAccept {
+			t.Errorf("Accept: %s should set Vary: Accept", c.accept)
+		}
+	}
+
+	u, w := getTestRecorder(http.MethodGet, "/", http.Header{HeaderKeyAccept: []string{"text/csv"}})
+	Negotiate(data, JSONEncoder(), CSVEncoder())(u)
+	if w.Body.String() != "a,b\n1,x\n2,\n" {
+		t.Errorf("unexpected CSV: %q", w.Body.String())
+	}
+}
+
+func TestServeXML(t *testing.T) {
+	data := Data{"name": "x", "count": 2, "tags": []string{"a", "b"}, "address": Data{"city": "y"}, "empty": nil, "1st": true}
+	u, w := getTestRecorder(http.MethodGet, "/", nil)
+	ServeXML(data)(u)
+	expected := xml.Header + `<Data><entry key="1st">true</entry><address><city>y</city></address><count>2</count><empty></empty><name>x</name><tags>a</tags><tags>b</tags></Data>`
+	if w.Body.String() != expected {
+		t.Errorf("unexpected XML: %s", w.Body.String())
+	}
+
+	result := Body()(getTestContextWithBody("http://text.de/", "text/xml", w.Body.String()))
+	d := result.BodyData()
+	if result.GetPanic() != nil || *d.String("name") != "x" || *d.Int64("count") != 2 || *d.Data("address").String("city") != "y" ||
+		len(d["tags"].([]interface{})) != 2 || *d.String("1st") != "true" || *d.String("empty") != "" {
+		t.Errorf("XML body was decoded into %v (%v)", d, result.GetPanic())
+	}
+
+	type item struct {
+		Name string `xml:"name"`
+	}
+	result = XMLBody[item]()(getTestContextWithBody("http://text.de/", ContentTypeXML, `<item><name>y</name></item>`))
+	if i, ok := BodyAs[item](*result); !ok || i.Name != "y" {
+		t.Errorf("XMLBody[item]() decoded %v (%v)", result.GetBody(), result.GetPanic())
+	}
+}
+
+func TestServeContentType(t *testing.T) {
+	cases := []struct {
+		part        WebPart
+		contentType string
+		length      string
+	}{
+		{ServeString("hello"), contentTypeTextUTF8, "5"},
+		{ServeJSON(Data{"a": 1}), ContentTypeJSON, "7"},
+		{ServeXML(Data{"a": 1}), contentTypeXMLUTF8, "60"},
+		{ServeBytes([]byte("<html><body></body></html>")), "text/html; charset=utf-8", "26"},
+		{ContentType(ContentTypeHTML).ServeString("<p>"), ContentTypeHTML, "3"},
+		{ServeReadCloser(func(WebUnit) (io.ReadCloser, error) { return ioutil.NopCloser(strings.NewReader("%PDF-")), nil }), "application/pdf", "5"},
+	}
+
+	for i, c := range cases {
+		u, w := getTestRecorder(http.MethodGet, "/", nil)
+		c.part(u)
+		if w.Header().Get(HeaderKeyContentType) != c.contentType || w.Header().Get(HeaderKeyContentLength) != c.length {
+			t.Errorf("case %d should have Content-Type %s and Content-Length %s but has %s and %s", i, c.contentType, c.length, w.Header().Get(HeaderKeyContentType), w.Header().Get(HeaderKeyContentLength))
+		}
+	}
+}
+
+type failingReader struct {
+	n int
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if f.n <= 0 {
+		return 0, errors.New("disk on fire")
+	}
+	if len(p) > f.n {
+		p = p[:f.n]
+	}
+	for i := range p {
+		p[i] = 'x'
+	}
+	f.n -= len(p)
+	return len(p), nil
+}
+
+func TestServeReadCloserError(t *testing.T) {
+	failAfter := func(n int) WebPart {
+		return ServeReadCloser(func(WebUnit) (io.ReadCloser, error) { return MakeClosable(&failingReader{n}, nil), nil })
+	}
+
+	u, w := getTestRecorder(http.MethodGet, "/", nil)
+	result := failAfter(1000)(u)
+	if w.Code != http.StatusInternalServerError || w.Body.String() != http.StatusText(http.StatusInternalServerError) || result.GetPanic() == nil {
+		t.Errorf("small body with read error should result in 500 but was %d %s", w.Code, w.Body.String())
+	}
+
+	u, w = getTestRecorder(http.MethodGet, "/", nil)
+	result = failAfter(ResponseBufferSize * 2)(u)
+	if w.Code != http.StatusOK || w.Body.Len() != ResponseBufferSize*2 || result.GetPanic() == nil || !result.aborted() {
+		t.Errorf("large body with read error should panic and abort but was %d %d %v", w.Code, w.Body.Len(), result.GetPanic())
+	}
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("router should abort the connection but panicked with %v", err)
+		}
+	}()
+	router{failAfter(ResponseBufferSize * 2)}.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestETags(t *testing.T) {
+	u, w := getTestRecorder(http.MethodGet, "/", nil)
+	ETags(false).ServeString("hello")(u)
+	etag := w.Header().Get(HeaderKeyETag)
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("response should have a strong ETag but was %d %q", w.Code, etag)
+	}
+
+	u, w = getTestRecorder(http.MethodGet, "/", http.Header{HeaderKeyIfNoneMatch: {`"other", W/` + etag}})
+	ETags(false).ServeString("hello")(u)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get(HeaderKeyETag) != etag {
+		t.Errorf("matching If-None-Match should result in 304 but was %d", w.Code)
+	}
+
+	u, w = getTestRecorder(http.MethodGet, "/", http.Header{HeaderKeyIfNoneMatch: {etag}})
+	ETags(false).ServeString("changed")(u)
+	if w.Code != http.StatusOK || w.Body.String() != "changed" {
+		t.Errorf("changed content should be served but was %d", w.Code)
+	}
+
+	u, w = getTestRecorder(http.MethodGet, "/", nil)
+	ETags(true).ServeReadCloser(func(WebUnit) (io.ReadCloser, error) { return ioutil.NopCloser(strings.NewReader("hello")), nil })(u)
+	if !strings.HasPrefix(w.Header().Get(HeaderKeyETag), `W/"`) {
+		t.Errorf("small ReadCloser should get a weak ETag but was %q", w.Header().Get(HeaderKeyETag))
+	}
+
+	u, w = getTestRecorder(http.MethodGet, "/", nil)
+	ETags(false).ServeReadCloser(func(WebUnit) (io.ReadCloser, error) {
+		return MakeClosable(bytes.NewReader(make([]byte, ResponseBufferSize+1)), nil), nil
+	})(u)
+	if w.Header().Get(HeaderKeyETag) != "" || w.Body.Len() != ResponseBufferSize+1 {
+		t.Errorf("large ReadCloser should not be hashed but got ETag %q", w.Header().Get(HeaderKeyETag))
+	}
+
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	generated := false
+	lazy := LastModified(modified).ServeBytesLazy(func(WebUnit) ([]byte, error) {
+		generated = true
+		return []byte("expensive"), nil
+	})
+	u, w = getTestRecorder(http.MethodGet, "/", http.Header{HeaderKeyIfModifiedSince: {modified.Format(http.TimeFormat)}})
+	lazy(u)
+	if w.Code != http.StatusNotModified || generated {
+		t.Errorf("unmodified content should not be generated but was %d %v", w.Code, generated)
+	}
+	u, w = getTestRecorder(http.MethodGet, "/", http.Header{HeaderKeyIfModifiedSince: {modified.Add(-time.Hour).Format(http.TimeFormat)}})
+	lazy(u)
+	if w.Code != http.StatusOK || !generated {
+		t.Errorf("modified content should be generated but was %d", w.Code)
+	}
+
+	file := filepath.Join(t.TempDir(), "data.txt")
+	os.WriteFile(file, []byte("file content"), 0644)
+	u, w = getTestRecorder(http.MethodGet, "/", nil)
+	ETags(false).ServeFile(file)(u)
+	u, w = getTestRecorder(http.MethodGet, "/", http.Header{HeaderKeyIfNoneMatch: {w.Header().Get(HeaderKeyETag)}})
+	ETags(false).ServeFile(file)(u)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("unchanged file should result in 304 but was %d", w.Code)
+	}
+}
+
+func TestServeRange(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data.txt")
+	os.WriteFile(file, []byte("0123456789"), 0644)
+	cases := []struct {
+		header       http.Header
+		status       int
+		contentRange string
+		body         string
+	}{
+		{http.Header{}, http.StatusOK, "", "0123456789"},
+		{http.Header{HeaderKeyRange: {"bytes=2-4"}}, http.StatusPartialContent, "bytes 2-4/10", "234"},
+		{http.Header{HeaderKeyRange: {"bytes=7-"}}, http.StatusPartialContent, "bytes 7-9/10", "789"},
+		{http.Header{HeaderKeyRange: {"bytes=-2"}}, http.StatusPartialContent, "bytes 8-9/10", "89"},
+		{http.Header{HeaderKeyRange: {"bytes=20-30"}}, http.StatusRequestedRangeNotSatisfiable, "bytes */10", ""},
+		{http.Header{HeaderKeyRange: {"lines=1-2"}}, http.StatusOK, "", "0123456789"},
+		{http.Header{HeaderKeyRange: {"bytes=2-4"}, HeaderKeyIfRange: {`"outdated"`}}, http.StatusOK, "", "0123456789"},
+	}
+	for i, c := range cases {
+		u, w := getTestRecorder(http.MethodGet, "/", c.header)
+		ServeFile(file)(u)
+		if w.Code != c.status || w.Header().Get(HeaderKeyContentRange) != c.contentRange || (c.body != "" && w.Body.String() != c.body) {
+			t.Errorf("case %d should be %d %q %q but was %d %q %q", i, c.status, c.contentRange, c.body, w.Code, w.Header().Get(HeaderKeyContentRange), w.Body.String())
+		}
+		if w.Header().Get(HeaderKeyAcceptRanges) != "bytes" {
+			t.Errorf("case %d should advertise Accept-Ranges", i)
+		}
+	}
+
+	u, w := getTestRecorder(http.MethodGet, "/", http.Header{HeaderKeyRange: {"bytes=0-1,5-6"}})
+	ServeReadCloser(func(WebUnit) (io.ReadCloser, error) { return MakeClosable(strings.NewReader("0123456789"), nil), nil })(u)
+	contentType := w.Header().Get(HeaderKeyContentType)
+	if w.Code != http.StatusPartialContent || !strings.HasPrefix(contentType, "multipart/byteranges; boundary=") || w.Header().Get(HeaderKeyContentLength) != strconv.Itoa(w.Body.Len()) {
+		t.Fatalf("multiple ranges should be served as multipart/byteranges but was %d %s", w.Code, contentType)
+	}
+	mr := multipart.NewReader(w.Body, strings.TrimPrefix(contentType, "multipart/byteranges; boundary="))
+	for _, expected := range []string{"01", "56"} {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(part)
+		if string(data) != expected || part.Header.Get(HeaderKeyContentType) != contentTypeTextUTF8 {
+			t.Errorf("part should be %q but was %q %v", expected, data, part.Header)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package grest
+
+import (
+	"io/fs"
+	"net/http"
+	pathpkg "path"
+	"strings"
+	"time"
+)
+
+//SPAOptions configure ServeSPAWith and ServeSPAFS
+type SPAOptions struct {
+	//Index is the file that is served for client side routes (default "index.html")
+	Index string
+	//Exclude are path prefixes that are not handled, ServeSPA returns nil for them so following routes can answer (nil = "/api")
+	Exclude []string
+	//Immutable returns true for asset names that contain a content hash, they are cached for a year (nil = names like "main.3f2a1b4c.js" or "index-B4x9kQ2z.css")
+	Immutable func(name string) bool
+	//FolderOptions for folders on disk (see ServeSPAWith)
+	FolderOptions FolderOptions
+}
+
+//ServeSPA hosts a single-page-application from the folder root
+//Existing files are served like with ServeFolder, other paths without file extension get the index.html, so client side routing works (missing assets are 404).
+//Hashed asset names (like "main.3f2a1b4c.js") are cached with "Cache-Control: public, max-age=31536000, immutable", the index.html with "Cache-Control: no-cache".
+//Paths below /api are not handled (nil), so API routes that did not match are not answered with the index.html
+func ServeSPA(root string) WebPart {
+	return ServeSPAWith(root, SPAOptions{})
+}
+
+//ServeSPA hosts a single-page-application from the folder root
+//Existing files are served like with ServeFolder, other paths without file extension get the index.html, so client side routing works (missing assets are 404).
+//Hashed asset names (like "main.3f2a1b4c.js") are cached with "Cache-Control: public, max-age=31536000, immutable", the index.html with "Cache-Control: no-cache".
+//Paths below /api are not handled (nil), so API routes that did not match are not answered with the index.html
+func (w WebPart) ServeSPA(root string) WebPart {
+	return Compose(w, ServeSPA(root))
+}
+
+//ServeSPAWith works like ServeSPA with the given options
+func ServeSPAWith(root string, options SPAOptions) WebPart {
+	return serveSPA(sandboxFS{root, options.FolderOptions}, options)
+}
+
+//ServeSPAWith works like ServeSPA with the given options
+func (w WebPart) ServeSPAWith(root string, options SPAOptions) WebPart {
+	return Compose(w, ServeSPAWith(root, options))
+}
+
+//ServeSPAFS works like ServeSPAWith for the folder root of fsys (e.g. an embed.FS with the build of the frontend)
+func ServeSPAFS(fsys fs.FS, root string, options SPAOptions) WebPart {
+	sub, err := fs.Sub(fsys, fsName(root))
+	if err != nil {
+		return Panic(err)
+	}
+	return serveSPA(sub, options)
+}
+
+//ServeSPAFS works like ServeSPAWith for the folder root of fsys (e.g. an embed.FS with the build of the frontend)
+func (w WebPart) ServeSPAFS(fsys fs.FS, root string, options SPAOptions) WebPart {
+	return Compose(w, ServeSPAFS(fsys, root, options))
+}
+
+//=== Helpers =====================================================================================
+
+//cacheImmutable and cacheNoCache are the Cache-Control values of ServeSPA
+var (
+	cacheImmutable = NewCacheControl().Public().MaxAge(365 * 24 * time.Hour).Immutable().String()
+	cacheNoCache   = NewCacheControl().NoCache().String()
+)
+
+//serveSPA serves the files of fsys with fallback to the index (see ServeSPA)
+func serveSPA(fsys fs.FS, options SPAOptions) WebPart {
+	index := options.Index
+	if index == "" {
+		index = "index.html"
+	}
+	index = fsName(index)
+	exclude := options.Exclude
+	if exclude == nil {
+		exclude = []string{"/api"}
+	}
+	immutable := options.Immutable
+	if immutable == nil {
+		immutable = isHashedAsset
+	}
+	hashes := &hashCache{}
+
+	return func(u WebUnit) *WebUnit {
+		p := u.Request.URL.Path
+		for _, prefix := range exclude {
+			prefix = strings.TrimSuffix(prefix, "/")
+			if p == prefix || strings.HasPrefix(p, prefix+"/") {
+				return nil
+			}
+		}
+
+		name := fsName(p)
+		hidden := !options.FolderOptions.ShowDotfiles && isDotfile(name)
+		if info, err := fs.Stat(fsys, name); err == nil && !info.IsDir() && !hidden {
+			switch {
+			case name == index:
+				u.Writer.Header().Set(HeaderKeyCacheControl, cacheNoCache)
+			case immutable(pathpkg.Base(name)):
+				u.Writer.Header().Set(HeaderKeyCacheControl, cacheImmutable)
+			}
+			return serveFSFile(fsys, name, hashes)(u)
+		}
+
+		if u.Request.Method != http.MethodGet && u.Request.Method != http.MethodHead {
+			return nil
+		}
+		if hidden || (name != "." && pathpkg.Ext(name) != "") {
+			//missing assets must not be answered with the index
+			u.Panic(fileError(pathpkg.Clean("/"+p), fs.ErrNotExist))
+			return servePanic(u)
+		}
+		u.Writer.Header().Set(HeaderKeyCacheControl, cacheNoCache)
+		return serveFSFile(fsys, index, hashes)(u)
+	}
+}
+
+//isHashedAsset returns true for file names with a content hash of at least 8 characters including a digit like "main.3f2a1b4c.js" or "index-B4x9kQ2z.css"
+func isHashedAsset(name string) bool {
+	ext := pathpkg.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if ext == "" {
+		return false
+	}
+	i := strings.LastIndexAny(base, ".-")
+	if i < 0 {
+		return false
+	}
+	hash := base[i+1:]
+	if len(hash) < 8 || !strings.ContainsAny(hash, "0123456789") {
+		return false
+	}
+	for _, r := range hash {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
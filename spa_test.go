@@ -0,0 +1,48 @@
+package grest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestServeSPA(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dist/index.html":              {Data: []byte("<div id=app></div>")},
+		"dist/assets/main.3f2a1b4c.js": {Data: []byte("app()")},
+		"dist/favicon.ico":             {Data: []byte("icon")},
+	}
+	routes := Choose(
+		Path("/api/users").ServeJSON([]string{"alice"}),
+		ServeSPAFS(fsys, "dist", SPAOptions{}),
+		NotFound().ServeString("not found"),
+	)
+	cases := []struct {
+		target       string
+		status       int
+		body         string
+		cacheControl string
+	}{
+		{"/", http.StatusOK, "<div id=app></div>", "no-cache"},
+		{"/users/42/settings", http.StatusOK, "<div id=app></div>", "no-cache"},
+		{"/assets/main.3f2a1b4c.js", http.StatusOK, "app()", "public, max-age=31536000, immutable"},
+		{"/favicon.ico", http.StatusOK, "icon", ""},
+		{"/assets/missing.js", http.StatusNotFound, "/assets/missing.js not found", ""},
+		{"/api/users", http.StatusOK, `["alice"]`, ""},
+		{"/api/unknown", http.StatusNotFound, "not found", ""},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		router{routes}.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.target, nil))
+		if w.Code != c.status || w.Body.String() != c.body || w.Header().Get(HeaderKeyCacheControl) != c.cacheControl {
+			t.Errorf("case %d %s should be %d %q %q but was %d %q %q", i, c.target, c.status, c.body, c.cacheControl, w.Code, w.Body.String(), w.Header().Get(HeaderKeyCacheControl))
+		}
+	}
+
+	for name, hashed := range map[string]bool{"main.3f2a1b4c.js": true, "index-B4x9kQ2z.css": true, "jquery-minified.js": false, "app.js": false, "logo.png": false} {
+		if isHashedAsset(name) != hashed {
+			t.Errorf("isHashedAsset(%q) should be %v", name, hashed)
+		}
+	}
+}
@@ -3,6 +3,9 @@ package grest
 import (
 	"context"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const statusKey contextKey = "status"
@@ -55,3 +58,245 @@ func NotFound() WebPart {
 func (w WebPart) NotFound() WebPart {
 	return Compose(w, NotFound())
 }
+
+//Created is a convinience call that sets the Created (201 status) and the Location of the new resource ("" = no Location)
+//Relative locations are resolved like with Redirect
+func Created(location string) WebPart {
+	return func(u WebUnit) *WebUnit {
+		if location != "" {
+			resolved, err := resolveLocation(u, location)
+			if err != nil {
+				u.Panic(NewStatusError(http.StatusInternalServerError, "invalid location %q: %v", location, err))
+				return &u
+			}
+			u.Writer.Header().Set(HeaderKeyLocation, resolved)
+		}
+		return Status(http.StatusCreated)(u)
+	}
+}
+
+//Created is a convinience call that sets the Created (201 status) and the Location of the new resource ("" = no Location)
+//Relative locations are resolved like with Redirect
+func (w WebPart) Created(location string) WebPart {
+	return Compose(w, Created(location))
+}
+
+//Accepted is a convinience call that sets the Accepted (202 status)
+func Accepted() WebPart {
+	return Status(http.StatusAccepted)
+}
+
+//Accepted is a convinience call that sets the Accepted (202 status)
+func (w WebPart) Accepted() WebPart {
+	return Compose(w, Accepted())
+}
+
+//NoContent is a convinience call that sets the NoContent (204 status)
+//Bodies written by the following WebParts are dropped and the router sends the 204 even if no following WebPart writes a response
+func NoContent() WebPart {
+	return func(u WebUnit) *WebUnit {
+		if u.Context.Value(noContentKey) == nil {
+			nw := &noBodyWriter{ResponseWriter: u.Writer}
+			u.Writer = nw
+			u.Context = context.WithValue(u.Context, noContentKey, nw)
+		}
+		return Status(http.StatusNoContent)(u)
+	}
+}
+
+//NoContent is a convinience call that sets the NoContent (204 status)
+//Bodies written by the following WebParts are dropped and the router sends the 204 even if no following WebPart writes a response
+func (w WebPart) NoContent() WebPart {
+	return Compose(w, NoContent())
+}
+
+//Unauthorized is a convinience call that sets the Unauthorized (401 status) and the WWW-Authenticate header with the challenge (e.g. `Bearer realm="api"`, "" = no header)
+func Unauthorized(challenge string) WebPart {
+	return func(u WebUnit) *WebUnit {
+		if challenge != "" {
+			u.Writer.Header().Set(HeaderKeyWWWAuthenticate, challenge)
+		}
+		return Status(http.StatusUnauthorized)(u)
+	}
+}
+
+//Unauthorized is a convinience call that sets the Unauthorized (401 status) and the WWW-Authenticate header with the challenge (e.g. `Bearer realm="api"`, "" = no header)
+func (w WebPart) Unauthorized(challenge string) WebPart {
+	return Compose(w, Unauthorized(challenge))
+}
+
+//Forbidden is a convinience call that sets the Forbidden (403 status)
+func Forbidden() WebPart {
+	return Status(http.StatusForbidden)
+}
+
+//Forbidden is a convinience call that sets the Forbidden (403 status)
+func (w WebPart) Forbidden() WebPart {
+	return Compose(w, Forbidden())
+}
+
+//MethodNotAllowed is a convinience call that sets the MethodNotAllowed (405 status) and the Allow header with the allowed methods
+func MethodNotAllowed(allowed ...string) WebPart {
+	return func(u WebUnit) *WebUnit {
+		u.Writer.Header().Set(HeaderKeyAllow, strings.Join(allowed, ", "))
+		return Status(http.StatusMethodNotAllowed)(u)
+	}
+}
+
+//MethodNotAllowed is a convinience call that sets the MethodNotAllowed (405 status) and the Allow header with the allowed methods
+func (w WebPart) MethodNotAllowed(allowed ...string) WebPart {
+	return Compose(w, MethodNotAllowed(allowed...))
+}
+
+//Conflict is a convinience call that sets the Conflict (409 status)
+func Conflict() WebPart {
+	return Status(http.StatusConflict)
+}
+
+//Conflict is a convinience call that sets the Conflict (409 status)
+func (w WebPart) Conflict() WebPart {
+	return Compose(w, Conflict())
+}
+
+//Gone is a convinience call that sets the Gone (410 status)
+func Gone() WebPart {
+	return Status(http.StatusGone)
+}
+
+//Gone is a convinience call that sets the Gone (410 status)
+func (w WebPart) Gone() WebPart {
+	return Compose(w, Gone())
+}
+
+//UnprocessableEntity is a convinience call that sets the UnprocessableEntity (422 status)
+func UnprocessableEntity() WebPart {
+	return Status(http.StatusUnprocessableEntity)
+}
+
+//UnprocessableEntity is a convinience call that sets the UnprocessableEntity (422 status)
+func (w WebPart) UnprocessableEntity() WebPart {
+	return Compose(w, UnprocessableEntity())
+}
+
+//TooManyRequests is a convinience call that sets the TooManyRequests (429 status) and the Retry-After header in seconds (0 = no header)
+func TooManyRequests(retryAfter time.Duration) WebPart {
+	return func(u WebUnit) *WebUnit {
+		setRetryAfter(u, retryAfter)
+		return Status(http.StatusTooManyRequests)(u)
+	}
+}
+
+//TooManyRequests is a convinience call that sets the TooManyRequests (429 status) and the Retry-After header in seconds (0 = no header)
+func (w WebPart) TooManyRequests(retryAfter time.Duration) WebPart {
+	return Compose(w, TooManyRequests(retryAfter))
+}
+
+//InternalServerError is a convinience call that sets the InternalServerError (500 status)
+func InternalServerError() WebPart {
+	return Status(http.StatusInternalServerError)
+}
+
+//InternalServerError is a convinience call that sets the InternalServerError (500 status)
+func (w WebPart) InternalServerError() WebPart {
+	return Compose(w, InternalServerError())
+}
+
+//NotImplemented is a convinience call that sets the NotImplemented (501 status)
+func NotImplemented() WebPart {
+	return Status(http.StatusNotImplemented)
+}
+
+//NotImplemented is a convinience call that sets the NotImplemented (501 status)
+func (w WebPart) NotImplemented() WebPart {
+	return Compose(w, NotImplemented())
+}
+
+//ServiceUnavailable is a convinience call that sets the ServiceUnavailable (503 status) and the Retry-After header in seconds (0 = no header)
+func ServiceUnavailable(retryAfter time.Duration) WebPart {
+	return func(u WebUnit) *WebUnit {
+		setRetryAfter(u, retryAfter)
+		return Status(http.StatusServiceUnavailable)(u)
+	}
+}
+
+//ServiceUnavailable is a convinience call that sets the ServiceUnavailable (503 status) and the Retry-After header in seconds (0 = no header)
+func (w WebPart) ServiceUnavailable(retryAfter time.Duration) WebPart {
+	return Compose(w, ServiceUnavailable(retryAfter))
+}
+
+//IsInformational returns true for 1xx status codes
+func IsInformational(code int) bool {
+	return code >= 100 && code < 200
+}
+
+//IsSuccess returns true for 2xx status codes
+func IsSuccess(code int) bool {
+	return code >= 200 && code < 300
+}
+
+//IsRedirection returns true for 3xx status codes
+func IsRedirection(code int) bool {
+	return code >= 300 && code < 400
+}
+
+//IsClientError returns true for 4xx status codes
+func IsClientError(code int) bool {
+	return code >= 400 && code < 500
+}
+
+//IsServerError returns true for 5xx status codes
+func IsServerError(code int) bool {
+	return code >= 500 && code < 600
+}
+
+//IsError returns true for 4xx and 5xx status codes
+func IsError(code int) bool {
+	return IsClientError(code) || IsServerError(code)
+}
+
+//=== Helpers =====================================================================================
+
+//setRetryAfter sets the Retry-After header in whole seconds (rounded up) if d is positive
+func setRetryAfter(u WebUnit, d time.Duration) {
+	if d > 0 {
+		u.Writer.Header().Set(HeaderKeyRetryAfter, strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10))
+	}
+}
+
+//noContentKey to save the *noBodyWriter of NoContent() in the context, the router finishes it if the WebUnit is the result of the routes
+const noContentKey contextKey = "noContent"
+
+//noBodyWriter drops the bodies of responses whose status must not have one and sends 204 (No Content) if nothing was written (see NoContent)
+type noBodyWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (nw *noBodyWriter) WriteHeader(status int) {
+	if nw.status != 0 {
+		return
+	}
+	nw.status = status
+	if !bodyAllowed(status) {
+		nw.Header().Del(HeaderKeyContentType)
+		nw.Header().Del(HeaderKeyContentLength)
+	}
+	nw.ResponseWriter.WriteHeader(status)
+}
+
+func (nw *noBodyWriter) Write(data []byte) (int, error) {
+	if nw.status == 0 {
+		nw.WriteHeader(http.StatusNoContent)
+	}
+	if !bodyAllowed(nw.status) {
+		return len(data), nil
+	}
+	return nw.ResponseWriter.Write(data)
+}
+
+//finish sends 204 (No Content) if no response was written
+func (nw *noBodyWriter) finish() {
+	if nw.status == 0 {
+		nw.WriteHeader(http.StatusNoContent)
+	}
+}
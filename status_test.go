@@ -0,0 +1,61 @@
+package grest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatusHelpers(t *testing.T) {
+	cases := []struct {
+		part   WebPart
+		code   int
+		header string
+		value  string
+	}{
+		{Created("42").ServeJSON(Data{"id": 42}), http.StatusCreated, HeaderKeyLocation, "/items/42"},
+		{Accepted().ServeString("queued"), http.StatusAccepted, "", ""},
+		{Unauthorized(`Bearer realm="api"`).ServeString("login"), http.StatusUnauthorized, HeaderKeyWWWAuthenticate, `Bearer realm="api"`},
+		{MethodNotAllowed(http.MethodGet, http.MethodHead).ServeString("no"), http.StatusMethodNotAllowed, HeaderKeyAllow, "GET, HEAD"},
+		{TooManyRequests(1500 * time.Millisecond).ServeString("slow down"), http.StatusTooManyRequests, HeaderKeyRetryAfter, "2"},
+		{ServiceUnavailable(0).ServeString("maintenance"), http.StatusServiceUnavailable, HeaderKeyRetryAfter, ""},
+		{NoContent(), http.StatusNoContent, HeaderKeyContentType, ""},
+		{NoContent().ServeJSON(Data{"dropped": true}), http.StatusNoContent, HeaderKeyContentLength, ""},
+		{Status(http.StatusNoContent).ServeString("dropped"), http.StatusNoContent, HeaderKeyContentType, ""},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		router{c.part}.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items/", nil))
+		if w.Code != c.code {
+			t.Errorf("case %d should be %d but was %d", i, c.code, w.Code)
+		}
+		if c.header != "" && w.Header().Get(c.header) != c.value {
+			t.Errorf("case %d should have %s %q but was %q", i, c.header, c.value, w.Header().Get(c.header))
+		}
+		if c.code == http.StatusNoContent && w.Body.Len() != 0 {
+			t.Errorf("case %d should not have a body but was %q", i, w.Body.String())
+		}
+	}
+
+	//NoContent of a rejected option has no effect
+	routes := Choose(Compose(NoContent(), Path("/a")), Compose(Path("/b"), SetHeader("X", "1")))
+	w := httptest.NewRecorder()
+	router{routes}.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/b", nil))
+	if w.Code != http.StatusOK || w.Header().Get("X") != "1" {
+		t.Errorf("NoContent of a rejected option should not answer the request but was %d %v", w.Code, w.Header())
+	}
+
+	u, rec := getTestRecorder(http.MethodGet, "/", nil)
+	counter := &headerCounter{ResponseWriter: rec}
+	u.Writer = counter
+	result := NoContent().ServeString("dropped")(u)
+	result.Writer.WriteHeader(http.StatusInternalServerError)
+	if counter.calls != 1 || rec.Code != http.StatusNoContent {
+		t.Errorf("NoContent should forward WriteHeader only once but was called %d times", counter.calls)
+	}
+
+	if !IsSuccess(204) || IsSuccess(304) || !IsRedirection(308) || !IsClientError(404) || !IsServerError(503) || !IsInformational(101) || !IsError(400) || IsError(299) {
+		t.Errorf("status classes are wrong")
+	}
+}
@@ -0,0 +1,106 @@
+package grest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+//MaxLineSize is the maximum length in bytes of a single line read by StreamLines and the record streaming WebParts
+const MaxLineSize = 1 << 20
+
+//StreamLines reads the request body line by line without buffering the whole body and calls onLine for each non-empty line (without the line break).
+//The line slice is only valid until onLine returns.
+//If onLine returns an error, reading stops and the WebUnit panics with it.
+//Lines longer than MaxLineSize result in a panic with status 413
+func StreamLines(onLine func(u WebUnit, line []byte) error) WebPart {
+	return func(u WebUnit) *WebUnit {
+		if err := scanLines(u, onLine); err != nil {
+			u.Panic(err)
+		}
+		return &u
+	}
+}
+
+//StreamLines reads the request body line by line without buffering the whole body and calls onLine for each non-empty line (without the line break).
+//The line slice is only valid until onLine returns.
+//If onLine returns an error, reading stops and the WebUnit panics with it.
+//Lines longer than MaxLineSize result in a panic with status 413
+func (w WebPart) StreamLines(onLine func(u WebUnit, line []byte) error) WebPart {
+	return Compose(w, StreamLines(onLine))
+}
+
+//StreamRecords reads a newline delimited JSON body (NDJSON) record by record and calls onRecord for each JSON object.
+//Empty lines are skipped, lines that are no JSON object result in a panic with status 400.
+//If onRecord returns an error, reading stops and the WebUnit panics with it
+func StreamRecords(onRecord func(u WebUnit, record Data) error) WebPart {
+	return StreamLines(func(u WebUnit, line []byte) error {
+		var d Data
+		err := decodeJSON(line, &d)
+		if err == nil && d == nil {
+			err = NewStatusError(http.StatusBadRequest, "not a JSON object")
+		}
+		if err != nil {
+			return err
+		}
+		return onRecord(u, d)
+	})
+}
+
+//StreamRecords reads a newline delimited JSON body (NDJSON) record by record and calls onRecord for each JSON object.
+//Empty lines are skipped, lines that are no JSON object result in a panic with status 400.
+//If onRecord returns an error, reading stops and the WebUnit panics with it
+func (w WebPart) StreamRecords(onRecord func(u WebUnit, record Data) error) WebPart {
+	return Compose(w, StreamRecords(onRecord))
+}
+
+//StreamJSONRecords reads a newline delimited JSON body (NDJSON) record by record and calls onRecord with each line decoded into a T.
+//Empty lines are skipped, lines that cannot be decoded into T result in a panic with status 400.
+//If onRecord returns an error, reading stops and the WebUnit panics with it
+func StreamJSONRecords[T any](onRecord func(u WebUnit, record T) error) WebPart {
+	return StreamLines(func(u WebUnit, line []byte) error {
+		var v T
+		if err := decodeJSON(line, &v); err != nil {
+			return err
+		}
+		return onRecord(u, v)
+	})
+}
+
+//=== Helpers =====================================================================================
+
+//scanLines calls onLine for every non-empty line of the request body, errors are prefixed with the line number
+func scanLines(u WebUnit, onLine func(WebUnit, []byte) error) error {
+	if u.Request.Body == nil {
+		return nil
+	}
+	scanner := bufio.NewScanner(u.Request.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxLineSize)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSuffix(scanner.Bytes(), []byte("\r"))
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err := onLine(u, line); err != nil {
+			return lineError(lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return lineError(lineNumber+1, NewStatusError(http.StatusRequestEntityTooLarge, "line is longer than %d bytes", MaxLineSize))
+		}
+		return bodyError(err)
+	}
+	return nil
+}
+
+//lineError prefixes the message of err with the line number, keeping the status of a StatusError
+func lineError(lineNumber int, err error) error {
+	if status := ErrorStatus(err); status != http.StatusInternalServerError {
+		return StatusError{status, fmt.Errorf("line %d: %w", lineNumber, err)}
+	}
+	return fmt.Errorf("line %d: %w", lineNumber, err)
+}
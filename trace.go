@@ -0,0 +1,205 @@
+package grest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//traceKey to save a *Trace in the context of the WebUnit
+const traceKey contextKey = "trace"
+
+//TraceEntry is a single recorded evaluation of a WebPart (a Choose option or a Named WebPart)
+type TraceEntry struct {
+	//Path is the name of the entry prefixed with the names of all enclosing entries, e.g.: Choose[2]/api/Choose[0]
+	Path  string
+	Depth int
+	//Matched is true if the WebPart returned a non-nil WebUnit
+	Matched bool
+	//Done is false as long as the WebPart is still being evaluated
+	Done    bool
+	Elapsed time.Duration
+	//Note explains why the WebPart returned nil, if known
+	Note string
+}
+
+//String formats the entry like: Choose[1]/Choose[0] = nil (rejected by Compose part 0) 12µs
+func (e TraceEntry) String() string {
+	result := "running"
+	if e.Done && e.Matched {
+		result = "ok"
+	} else if e.Done {
+		result = "nil"
+	}
+	s := fmt.Sprintf("%s = %s", e.Path, result)
+	if e.Note != "" {
+		s += fmt.Sprintf(" (%s)", e.Note)
+	}
+	if e.Done {
+		s += " " + e.Elapsed.String()
+	}
+	return s
+}
+
+//Trace records the evaluation path through Choose, Compose and Named WebParts of a single request
+type Trace struct {
+	mu      sync.Mutex
+	entries []TraceEntry
+	open    []int
+}
+
+//GetTrace returns the Trace of this WebUnit or nil if tracing is not enabled
+func (u WebUnit) GetTrace() *Trace {
+	t, _ := u.Context.Value(traceKey).(*Trace)
+	return t
+}
+
+//Entries returns a copy of the entries recorded so far in evaluation order
+func (t *Trace) Entries() []TraceEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TraceEntry{}, t.entries...)
+}
+
+//String returns the recorded entries one per line, indented by depth
+func (t *Trace) String() string {
+	var b strings.Builder
+	for _, e := range t.Entries() {
+		b.WriteString(strings.Repeat("  ", e.Depth))
+		b.WriteString(e.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+//Header returns the recorded entries in a single line suitable for a http header (µs are written as us)
+func (t *Trace) Header() string {
+	entries := t.Entries()
+	parts := make([]string, 0, len(entries))
+	for _, e := range entries {
+		parts = append(parts, strings.Replace(e.String(), "µ", "u", -1))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (t *Trace) begin(name string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	path := name
+	if len(t.open) > 0 {
+		path = t.entries[t.open[len(t.open)-1]].Path + "/" + name
+	}
+	t.entries = append(t.entries, TraceEntry{Path: path, Depth: len(t.open)})
+	i := len(t.entries) - 1
+	t.open = append(t.open, i)
+	return i
+}
+
+func (t *Trace) end(i int, matched bool, elapsed time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries[i].Done = true
+	t.entries[i].Matched = matched
+	t.entries[i].Elapsed = elapsed
+	if len(t.open) > 0 && t.open[len(t.open)-1] == i {
+		t.open = t.open[:len(t.open)-1]
+	}
+}
+
+//note annotates the innermost running entry (only the first note is kept)
+func (t *Trace) note(format string, args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.open) > 0 {
+		e := &t.entries[t.open[len(t.open)-1]]
+		if e.Note == "" {
+			e.Note = fmt.Sprintf(format, args...)
+		}
+	}
+}
+
+//traced evaluates part and records it as entry with given name (with "[index]" appended if index >= 0) if the WebUnit has a Trace
+func traced(name string, index int, part WebPart, u WebUnit) *WebUnit {
+	t := u.GetTrace()
+	if t == nil {
+		return part(u)
+	}
+	if index >= 0 {
+		name = fmt.Sprintf("%s[%d]", name, index)
+	}
+	i := t.begin(name)
+	start := time.Now()
+	result := part(u)
+	t.end(i, result != nil, time.Since(start))
+	return result
+}
+
+//Named gives a WebPart a name that shows up in the Trace when tracing is enabled (see Debug)
+func Named(name string, part WebPart) WebPart {
+	return func(u WebUnit) *WebUnit {
+		return traced(name, -1, part, u)
+	}
+}
+
+//Named gives this WebPart a name that shows up in the Trace when tracing is enabled (see Debug)
+func (w WebPart) Named(name string) WebPart {
+	return Named(name, w)
+}
+
+//Debug traces the evaluation of routes for requests that carry the header debugHeader (with any value).
+//If debugHeader is empty every request is traced, so only use this during development.
+//The evaluation path known at the time the response is written is sent in the X-Grest-Trace header,
+//the complete Trace is logged with TraceLogger after routes returned
+func Debug(debugHeader string, routes WebPart) WebPart {
+	return func(u WebUnit) *WebUnit {
+		if debugHeader != "" && u.Request.Header.Get(debugHeader) == "" {
+			return routes(u)
+		}
+		t := &Trace{}
+		u.Context = context.WithValue(u.Context, traceKey, t)
+		u.Writer = &traceWriter{ResponseWriter: u.Writer, trace: t}
+		result := traced("routes", -1, routes, u)
+		TraceLogger.Printf("%s %s\n%s", u.Request.Method, u.Request.URL, t)
+		return result
+	}
+}
+
+//TraceLogger is used by Debug to log the complete Trace of a request
+var TraceLogger = log.New(os.Stderr, "grest ", log.LstdFlags)
+
+//traceWriter puts the Trace into the response header right before the header is written
+type traceWriter struct {
+	http.ResponseWriter
+	trace       *Trace
+	wroteHeader bool
+}
+
+func (w *traceWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set(HeaderKeyTrace, w.trace.Header())
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *traceWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+//Flush sends the data written so far to the client (see http.Flusher)
+func (w *traceWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,49 @@
+package grest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	TraceLogger.SetOutput(ioutil.Discard)
+	defer TraceLogger.SetOutput(os.Stderr)
+	routes := Choose(
+		Path("/a").OK().ServeString("a"),
+		Named("b", Path("/b").OK().ServeString("b")),
+	)
+
+	u := getTestContext("http://text.de/b")
+	u.Request.Header = http.Header{"X-Debug": []string{"1"}}
+	w := u.Writer
+	if result := Debug("X-Debug", routes)(u); result == nil {
+		t.Fatal("Debug should not change the result of routes")
+	}
+
+	header := w.Header().Get(HeaderKeyTrace)
+	expected := "routes = running; routes/Choose[0] = nil (rejected by Compose part 0) "
+	if len(header) < len(expected) || header[:len(expected)] != expected {
+		t.Errorf("unexpected trace header: %s", header)
+	}
+
+	u = getTestContext("http://text.de/b")
+	u.Request.Header = http.Header{}
+	w = u.Writer
+	Debug("X-Debug", routes)(u)
+	if w.Header().Get(HeaderKeyTrace) != "" {
+		t.Error("requests without debug header should not be traced")
+	}
+
+	recorder := httptest.NewRecorder()
+	flush := func(u WebUnit) *WebUnit {
+		u.Writer.(http.Flusher).Flush()
+		return &u
+	}
+	Debug("", flush)(WebUnit{recorder, httptest.NewRequest(http.MethodGet, "/", nil), u.Context})
+	if !recorder.Flushed {
+		t.Error("Debug should forward Flush to the wrapped writer")
+	}
+}
@@ -0,0 +1,355 @@
+package grest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+)
+
+//Rule checks the value of key in a Data object and returns an error if it is invalid
+//Rules other than Required accept missing keys and nil values.
+//Errors of type FieldErrors are treated as errors of nested fields (see Nested and Items)
+type Rule func(d Data, key string) error
+
+//Schema maps keys of a Data object to the rules their values have to satisfy
+type Schema map[string][]Rule
+
+//Validate checks d against the schema and returns all violations (nil if d is valid)
+//Nested fields are named like address.city or items[2].name
+func (s Schema) Validate(d Data) FieldErrors {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs FieldErrors
+	for _, key := range keys {
+		for _, rule := range s[key] {
+			err := rule(d, key)
+			if err == nil {
+				continue
+			}
+			if nested, ok := err.(FieldErrors); ok {
+				for _, e := range nested {
+					if strings.HasPrefix(e.Field, "[") {
+						e.Field = key + e.Field
+					} else {
+						e.Field = key + "." + e.Field
+					}
+					errs = append(errs, e)
+				}
+			} else {
+				errs = append(errs, FieldError{Field: key, Message: err.Error()})
+			}
+			//report only the first violated rule per key
+			break
+		}
+	}
+	return errs
+}
+
+//Validate checks this Data object against the schema and returns all violations (nil if it is valid)
+func (d Data) Validate(schema Schema) FieldErrors {
+	return schema.Validate(d)
+}
+
+//ValidateStruct checks a struct against the schema, the keys of the schema are the JSON names of the fields
+func ValidateStruct(v interface{}, schema Schema) FieldErrors {
+	d, err := toData(v)
+	if err != nil {
+		return FieldErrors{{Field: "", Message: err.Error()}}
+	}
+	return schema.Validate(d)
+}
+
+//Validate checks the parsed request body (see Body(), Form() and JSONBody[T]()) against the schema
+//If it is invalid the WebUnit panics with status 422 (Unprocessable Entity) and the FieldErrors.
+//A missing body is validated like an empty Data object
+func Validate(schema Schema) WebPart {
+	return func(u WebUnit) *WebUnit {
+		return validateWith(u, "body", u.GetBody(), schema)
+	}
+}
+
+//Validate checks the parsed request body (see Body(), Form() and JSONBody[T]()) against the schema
+//If it is invalid the WebUnit panics with status 422 (Unprocessable Entity) and the FieldErrors.
+//A missing body is validated like an empty Data object
+func (w WebPart) Validate(schema Schema) WebPart {
+	return Compose(w, Validate(schema))
+}
+
+//ValidateQuery checks the URL query parameters against the schema
+//If they are invalid the WebUnit panics with status 422 (Unprocessable Entity) and the FieldErrors
+func ValidateQuery(schema Schema) WebPart {
+	return func(u WebUnit) *WebUnit {
+		return validateWith(u, "query", valuesToData(u.Request.URL.Query()), schema)
+	}
+}
+
+//ValidateQuery checks the URL query parameters against the schema
+//If they are invalid the WebUnit panics with status 422 (Unprocessable Entity) and the FieldErrors
+func (w WebPart) ValidateQuery(schema Schema) WebPart {
+	return Compose(w, ValidateQuery(schema))
+}
+
+//ValidateBound checks the struct filled by Bind[T]() against the schema (keys are the JSON names of the fields)
+//If it is invalid the WebUnit panics with status 422 (Unprocessable Entity) and the FieldErrors
+func ValidateBound(schema Schema) WebPart {
+	return func(u WebUnit) *WebUnit {
+		return validateWith(u, "", u.Context.Value(boundKey), schema)
+	}
+}
+
+//ValidateBound checks the struct filled by Bind[T]() against the schema (keys are the JSON names of the fields)
+//If it is invalid the WebUnit panics with status 422 (Unprocessable Entity) and the FieldErrors
+func (w WebPart) ValidateBound(schema Schema) WebPart {
+	return Compose(w, ValidateBound(schema))
+}
+
+//=== Rules =======================================================================================
+
+//Required fails if the key is missing, nil or an empty string
+func Required() Rule {
+	return func(d Data, key string) error {
+		if d[key] == nil || *d.String(key) == "" {
+			return fmt.Errorf("is required")
+		}
+		return nil
+	}
+}
+
+//Min fails if the value is not a number or smaller than min
+func Min(min float64) Rule {
+	return func(d Data, key string) error {
+		n, err := number(d, key)
+		if n == nil || err != nil {
+			return err
+		}
+		if n.LessThan(decimal.NewFromFloat(min)) {
+			return fmt.Errorf("must be at least %v", min)
+		}
+		return nil
+	}
+}
+
+//Max fails if the value is not a number or greater than max
+func Max(max float64) Rule {
+	return func(d Data, key string) error {
+		n, err := number(d, key)
+		if n == nil || err != nil {
+			return err
+		}
+		if n.GreaterThan(decimal.NewFromFloat(max)) {
+			return fmt.Errorf("must be at most %v", max)
+		}
+		return nil
+	}
+}
+
+//Integer fails if the value is not an integer
+func Integer() Rule {
+	return func(d Data, key string) error {
+		if d[key] != nil && d.Int64(key) == nil {
+			return fmt.Errorf("must be an integer")
+		}
+		return nil
+	}
+}
+
+//MinLength fails if a string has less than min characters or an array less than min elements
+func MinLength(min int) Rule {
+	return func(d Data, key string) error {
+		if l, ok := length(d, key); ok && l < min {
+			return fmt.Errorf("must have a length of at least %d", min)
+		}
+		return nil
+	}
+}
+
+//MaxLength fails if a string has more than max characters or an array more than max elements
+func MaxLength(max int) Rule {
+	return func(d Data, key string) error {
+		if l, ok := length(d, key); ok && l > max {
+			return fmt.Errorf("must have a length of at most %d", max)
+		}
+		return nil
+	}
+}
+
+//Pattern fails if the value does not match the regular expression (panics if expr cannot be compiled)
+func Pattern(expr string) Rule {
+	re := regexp.MustCompile(expr)
+	return func(d Data, key string) error {
+		if d[key] != nil && !re.MatchString(*d.String(key)) {
+			return fmt.Errorf("must match %s", expr)
+		}
+		return nil
+	}
+}
+
+//Enum fails if the value is not one of the given values (compared by their string representation)
+func Enum(values ...interface{}) Rule {
+	return func(d Data, key string) error {
+		if d[key] == nil {
+			return nil
+		}
+		s := *d.String(key)
+		for _, v := range values {
+			if fmt.Sprint(v) == s {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %v", values)
+	}
+}
+
+//Email fails if the value is not a plain email address like name@example.com
+func Email() Rule {
+	return func(d Data, key string) error {
+		if d[key] == nil {
+			return nil
+		}
+		s := *d.String(key)
+		if a, err := mail.ParseAddress(s); err != nil || a.Address != s {
+			return fmt.Errorf("must be an email address")
+		}
+		return nil
+	}
+}
+
+//URL fails if the value is not an absolute URL
+func URL() Rule {
+	return func(d Data, key string) error {
+		if d[key] == nil {
+			return nil
+		}
+		if u, err := url.Parse(*d.String(key)); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("must be an absolute URL")
+		}
+		return nil
+	}
+}
+
+//Nested validates the value as Data object with its own schema
+func Nested(schema Schema) Rule {
+	return func(d Data, key string) error {
+		if d[key] == nil {
+			return nil
+		}
+		inner := d.Data(key)
+		if inner == nil {
+			return fmt.Errorf("must be an object")
+		}
+		if errs := schema.Validate(*inner); len(errs) > 0 {
+			return errs
+		}
+		return nil
+	}
+}
+
+//Items validates every element of an array of Data objects (like Datas) with the schema
+func Items(schema Schema) Rule {
+	return Each(Nested(schema))
+}
+
+//Each applies the rules to every element of an array
+func Each(rules ...Rule) Rule {
+	return func(d Data, key string) error {
+		if d[key] == nil {
+			return nil
+		}
+		elements, ok := array(d[key])
+		if !ok {
+			return fmt.Errorf("must be an array")
+		}
+		var errs FieldErrors
+		for i, element := range elements {
+			name := fmt.Sprintf("[%d]", i)
+			errs = append(errs, Schema{name: rules}.Validate(Data{name: element})...)
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+		return nil
+	}
+}
+
+//=== Helpers =====================================================================================
+
+//validateWith validates v (Data or a struct) and puts the FieldErrors with status 422 into the panic context
+func validateWith(u WebUnit, source string, v interface{}, schema Schema) *WebUnit {
+	var errs FieldErrors
+	if d, ok := v.(Data); ok {
+		errs = schema.Validate(d)
+	} else if v == nil {
+		errs = schema.Validate(Data{})
+	} else {
+		errs = ValidateStruct(v, schema)
+	}
+	if len(errs) > 0 {
+		for i := range errs {
+			errs[i].Source = source
+		}
+		u.Panic(StatusError{http.StatusUnprocessableEntity, errs})
+	}
+	return &u
+}
+
+//toData converts a struct into a Data object by its JSON representation
+func toData(v interface{}) (Data, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var d Data
+	if err := decodeJSON(data, &d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+//number returns the value as decimal, nil for missing values and an error if it is not a number
+func number(d Data, key string) (*decimal.Decimal, error) {
+	if d[key] == nil {
+		return nil, nil
+	}
+	n := d.Decimal(key)
+	if n == nil {
+		return nil, fmt.Errorf("must be a number")
+	}
+	return n, nil
+}
+
+//length returns the number of characters of a string or elements of an array
+func length(d Data, key string) (int, bool) {
+	if d[key] == nil {
+		return 0, false
+	}
+	if elements, ok := array(d[key]); ok {
+		return len(elements), true
+	}
+	return utf8.RuneCountInString(*d.String(key)), true
+}
+
+//array returns the elements of slices and arrays (like Datas, []interface{} or []string)
+func array(v interface{}) ([]interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	elements := make([]interface{}, rv.Len())
+	for i := range elements {
+		elements[i] = rv.Index(i).Interface()
+	}
+	return elements, true
+}
@@ -0,0 +1,123 @@
+package grest
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	schema := Schema{
+		"name":  {Required(), MinLength(2), MaxLength(5)},
+		"age":   {Integer(), Min(0), Max(150)},
+		"email": {Email()},
+		"site":  {URL()},
+		"code":  {Pattern("^[A-Z]{3}$")},
+		"role":  {Enum("admin", "user")},
+		"address": {Nested(Schema{
+			"city": {Required()},
+		})},
+		"items": {Items(Schema{
+			"count": {Required(), Min(1)},
+		})},
+		"tags": {Each(MaxLength(3))},
+	}
+
+	cases := []struct {
+		data   Data
+		fields []string
+	}{
+		{Data{"name": "abc"}, nil},
+		{Data{"name": "abc", "age": 30, "email": "a@b.de", "site": "https://x.de/a", "code": "ABC", "role": "user"}, nil},
+		{Data{}, []string{"name"}},
+		{Data{"name": "a"}, []string{"name"}},
+		{Data{"name": "abcdef"}, []string{"name"}},
+		{Data{"name": "abc", "age": -1}, []string{"age"}},
+		{Data{"name": "abc", "age": "1.5"}, []string{"age"}},
+		{Data{"name": "abc", "email": "Name <a@b.de>", "site": "/relative"}, []string{"email", "site"}},
+		{Data{"name": "abc", "code": "abc", "role": "root"}, []string{"code", "role"}},
+		{Data{"name": "abc", "address": Data{}}, []string{"address.city"}},
+		{Data{"name": "abc", "address": "nowhere"}, []string{"address"}},
+		{Data{"name": "abc", "items": Datas{{"count": 1}, {"count": 0}, {}}}, []string{"items[1].count", "items[2].count"}},
+		{Data{"name": "abc", "tags": []string{"a", "long"}}, []string{"tags[1]"}},
+	}
+
+	for _, c := range cases {
+		errs := c.data.Validate(schema)
+		if len(errs) != len(c.fields) {
+			t.Errorf("Validate(%v) should fail for %v but got %v", c.data, c.fields, errs)
+			continue
+		}
+		for i, e := range errs {
+			if e.Field != c.fields[i] {
+				t.Errorf("Validate(%v) should fail for %v but got %v", c.data, c.fields, errs)
+			}
+		}
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	sut := Body().Validate(Schema{"name": {Required()}})
+
+	result := sut(getTestContextWithBody("http://text.de/", ContentTypeJSON, `{"name": "x"}`))
+	if result.GetPanic() != nil {
+		t.Errorf("valid body should not panic but got %v", result.GetPanic())
+	}
+
+	result = sut(getTestContextWithBody("http://text.de/", ContentTypeJSON, `{"other": "x"}`))
+	var errs FieldErrors
+	if result.GetPanicStatus() != http.StatusUnprocessableEntity || !errors.As(result.GetPanic(), &errs) || errs[0].Source != "body" {
+		t.Errorf("invalid body should panic with 422 but got %v", result.GetPanic())
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema := MustJSONSchema([]byte(`{
+		"type": "object",
+		"required": ["name", "address"],
+		"properties": {
+			"name": {"type": "string", "minLength": 2, "pattern": "^[a-z]+$"},
+			"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+			"role": {"enum": ["admin", "user"]},
+			"address": {"$ref": "#/$defs/address"},
+			"tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}}
+		},
+		"additionalProperties": false,
+		"$defs": {
+			"address": {"type": "object", "required": ["city"], "properties": {"city": {"type": "string"}}}
+		}
+	}`))
+
+	cases := []struct {
+		body     string
+		pointers []string
+	}{
+		{`{"name": "abc", "address": {"city": "x"}}`, nil},
+		{`{"name": "abc", "age": 30, "role": "user", "tags": ["a"], "address": {"city": "x"}}`, nil},
+		{`{}`, []string{"/name", "/address"}},
+		{`{"name": "A", "address": {}}`, []string{"/address/city", "/name", "/name"}},
+		{`{"name": "abc", "age": 1.5, "address": {"city": "x"}}`, []string{"/age"}},
+		{`{"name": "abc", "age": 150, "role": "root", "address": {"city": "x"}}`, []string{"/age", "/role"}},
+		{`{"name": "abc", "tags": ["a", 1, "c"], "address": {"city": "x"}}`, []string{"/tags", "/tags/1"}},
+		{`{"name": "abc", "other": 1, "address": {"city": 5}}`, []string{"/address/city", "/other"}},
+	}
+
+	for _, c := range cases {
+		result := Body().ValidateJSONSchema(schema)(getTestContextWithBody("http://text.de/", ContentTypeJSON, c.body))
+		var errs FieldErrors
+		errors.As(result.GetPanic(), &errs)
+		if len(errs) != len(c.pointers) {
+			t.Errorf("%s should fail for %v but got %v", c.body, c.pointers, errs)
+			continue
+		}
+		for i, e := range errs {
+			if e.Field != c.pointers[i] {
+				t.Errorf("%s should fail for %v but got %v", c.body, c.pointers, errs)
+				break
+			}
+		}
+		if len(errs) > 0 && result.GetPanicStatus() != http.StatusUnprocessableEntity {
+			t.Errorf("%s should panic with 422 but got %d", c.body, result.GetPanicStatus())
+		}
+	}
+}
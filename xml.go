@@ -0,0 +1,247 @@
+package grest
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+//ServeXML responses with an object encoded as XML (Data and Datas are supported, see Data.MarshalXML) with "Content-Type: application/xml; charset=utf-8"
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
+func ServeXML(obj interface{}) WebPart {
+	return serveBytes(contentTypeXMLUTF8, func(WebUnit) ([]byte, error) { return marshalXML(obj) })
+}
+
+//ServeXML responses with an object encoded as XML (Data and Datas are supported, see Data.MarshalXML) with "Content-Type: application/xml; charset=utf-8"
+//If the WebUnit is already in panic, the panic is served instead (see ErrorStatus for the status code)
+func (w WebPart) ServeXML(obj interface{}) WebPart {
+	return Compose(w, ServeXML(obj))
+}
+
+//XMLBody decodes the XML request body into a new T that can be read by the following WebParts with BodyAs[T](u)
+//Bodies that cannot be decoded into T result in a panic with status 400 (415 for an unsupported Content-Type, 413 if the body is larger than the body limit, see MaxBodySize)
+func XMLBody[T any]() WebPart {
+	return func(u WebUnit) *WebUnit {
+		var v T
+		if err := decodeBody(&u, &v, xmlDecoder); err != nil {
+			u.Panic(err)
+			return &u
+		}
+		u.Context = context.WithValue(u.Context, bodyKey, v)
+		return &u
+	}
+}
+
+//MarshalXML encodes a Data object as element with a child element for every key (sorted by key)
+//Nested Data objects become nested elements, slices become repeated elements with the same name and nil becomes an empty element.
+//Keys that are no valid XML names are written as <entry key="..."></entry>
+func (d Data) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := encodeXMLValue(e, xmlStart(k), d[k]); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+//UnmarshalXML decodes an element into a Data object (the reverse of MarshalXML)
+//Elements with child elements or attributes become Data objects, elements with only text become strings.
+//Repeated child elements become []interface{}, attributes are saved with the key "@name" and text next to child elements with "#text"
+func (d *Data) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	_, attrs := xmlName(start)
+	v, err := decodeXMLElement(dec, attrs)
+	if err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case Data:
+		*d = t
+	case string:
+		*d = Data{}
+		if t != "" {
+			(*d)["#text"] = t
+		}
+	}
+	return nil
+}
+
+//MarshalXML encodes Datas as element with a <Data> child element for each Data object
+func (ds Datas) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, d := range ds {
+		if err := d.MarshalXML(e, xml.StartElement{Name: xml.Name{Local: "Data"}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+//UnmarshalXML decodes every child element into a Data object (the reverse of MarshalXML)
+func (ds *Datas) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			var d Data
+			if err := d.UnmarshalXML(dec, t); err != nil {
+				return err
+			}
+			*ds = append(*ds, d)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+//=== Helpers =====================================================================================
+
+//contentTypeXMLUTF8 is used for XML responses
+const contentTypeXMLUTF8 = ContentTypeXML + "; charset=utf-8"
+
+//isXML returns true for application/xml, text/xml and all +xml types
+func isXML(mediaType string) bool {
+	return mediaType == ContentTypeXML || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+//decodeXML decodes an XML document into v
+func decodeXML(data []byte, v interface{}) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return NewStatusError(http.StatusBadRequest, "request body is empty")
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return StatusError{http.StatusBadRequest, fmt.Errorf("malformed XML body: %v", err)}
+	}
+	return nil
+}
+
+//marshalXML encodes v as XML document including the XML header
+func marshalXML(v interface{}) ([]byte, error) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+//xmlStart returns the start element for a Data key
+func xmlStart(key string) xml.StartElement {
+	if isXMLName(key) {
+		return xml.StartElement{Name: xml.Name{Local: key}}
+	}
+	return xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}}}
+}
+
+//xmlName returns the Data key of an element and its remaining attributes (reverse of xmlStart)
+func xmlName(start xml.StartElement) (string, []xml.Attr) {
+	if start.Name.Local == "entry" {
+		for i, a := range start.Attr {
+			if a.Name.Local == "key" {
+				return a.Value, append(append([]xml.Attr{}, start.Attr[:i]...), start.Attr[i+1:]...)
+			}
+		}
+	}
+	return start.Name.Local, start.Attr
+}
+
+//isXMLName returns true if s can be used as element name
+func isXMLName(s string) bool {
+	if s == "" || strings.HasPrefix(strings.ToLower(s), "xml") {
+		return false
+	}
+	for i, r := range s {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'))) {
+			return false
+		}
+	}
+	return true
+}
+
+//encodeXMLValue encodes a value of a Data object as element
+func encodeXMLValue(e *xml.Encoder, start xml.StartElement, v interface{}) error {
+	switch t := v.(type) {
+	case nil:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		return e.EncodeToken(start.End())
+	case Data:
+		return t.MarshalXML(e, start)
+	case map[string]interface{}:
+		return Data(t).MarshalXML(e, start)
+	case []byte:
+		return e.EncodeElement(string(t), start)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		for i := 0; i < rv.Len(); i++ {
+			if err := encodeXMLValue(e, start, rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return e.EncodeElement(v, start)
+}
+
+//decodeXMLElement decodes the content of an element that was started with the given attributes
+func decodeXMLElement(dec *xml.Decoder, attrs []xml.Attr) (interface{}, error) {
+	result := Data{}
+	for _, a := range attrs {
+		result["@"+a.Name.Local] = a.Value
+	}
+	var text strings.Builder
+	hasChildren := false
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			hasChildren = true
+			name, childAttrs := xmlName(t)
+			child, err := decodeXMLElement(dec, childAttrs)
+			if err != nil {
+				return nil, err
+			}
+			switch existing := result[name].(type) {
+			case nil:
+				result[name] = child
+			case []interface{}:
+				result[name] = append(existing, child)
+			default:
+				result[name] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if !hasChildren && len(attrs) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				result["#text"] = s
+			}
+			return result, nil
+		}
+	}
+}